@@ -2,106 +2,234 @@ package ast
 
 import "github.com/tobiashort/monkey/token"
 
-type NodeType = string
-
-const (
-	LET      = "LET"
-	YIELD    = "YIELD"
-	RETURN   = "RETURN"
-	EXPR     = "EXPR"
-	UNARY    = "UNARY"
-	BINARY   = "BINARY"
-	IDENT    = "IDENT"
-	LITERAL  = "LITERAL"
-	IF       = "IF"
-	IFEXPR   = "IFEXPR"
-	BLOCK    = "BLOCK"
-	FUNCTION = "FUNCTION"
-	FNEXPR   = "FNEXPR"
-	CALL     = "CALL"
-)
-
-type Node any
-type Ast []Node
+// Node is implemented by every node of the syntax tree, and only by
+// them. Span returns the range of source the node was parsed from, Pos
+// where that range starts, and String the node as canonical source.
+type Node interface {
+	Span() token.Span
+	Pos() token.Position
+	String() string
+	node()
+}
+
+// Statement is a node that can appear in a program or block.
+type Statement interface {
+	Node
+	statementNode()
+}
+
+// Expression is a node that produces a value.
+type Expression interface {
+	Node
+	expressionNode()
+}
 
+type Ast []Statement
+
+// Block is a statement in statement position, where yields pass through
+// it, and an expression elsewhere.
 type Block struct {
-	Type NodeType
-	Ast  Ast
+	Ast   Ast
+	Range token.Span
 }
 
 type Function struct {
-	Type       NodeType
 	Identifier token.Token
-	Parameters []Node
-	Block      Node
+	Parameters []IdentifierExpression
+	Block      Block
+	Range      token.Span
 }
 
+// IfStatement is an if with an optional else branch. Alternative is nil
+// if there is none.
 type IfStatement struct {
-	Type        NodeType
-	Condition   Node
-	Consequence Node
-	Alternative Node
+	Condition   Expression
+	Consequence Block
+	Alternative *Block
+	Range       token.Span
 }
 
 type LetStatement struct {
-	Type       NodeType
 	Identifier token.Token
-	Expression Node
+	Expression Expression
+	Range      token.Span
 }
 
 type ReturnStatement struct {
-	Type       NodeType
-	Expression Node
+	Expression Expression
+	Range      token.Span
 }
 
 type YieldStatement struct {
-	Type       NodeType
-	Expression Node
+	Expression Expression
+	Range      token.Span
 }
 
 type ExpressionStatement struct {
-	Type       NodeType
-	Expression Node
+	Expression Expression
+	Range      token.Span
 }
 
 type UnaryExpression struct {
-	Type     NodeType
 	Operator token.Token
-	Right    Node
+	Right    Expression
+	Range    token.Span
 }
 
 type BinaryExpression struct {
-	Type     NodeType
-	Left     Node
+	Left     Expression
 	Operator token.Token
-	Right    Node
+	Right    Expression
+	Range    token.Span
+}
+
+// ParenExpression is an expression in parentheses, which Range
+// includes.
+type ParenExpression struct {
+	Expression Expression
+	Range      token.Span
 }
 
 type IdentifierExpression struct {
-	Type       NodeType
 	Identifier token.Token
 }
 
 type LiteralExpression struct {
-	Type    NodeType
 	Literal token.Token
 }
 
 type CallExpression struct {
-	Type       NodeType
-	Identifier token.Token
-	Parameters []Node
+	Function   Expression
+	Paren      token.Token
+	Parameters []Expression
+	Range      token.Span
 }
 
 type IfExpression struct {
-	Type        NodeType
-	Condition   Node
-	Consequence Node
-	Alternative Node
+	Condition   Expression
+	Consequence Block
+	Alternative Block
+	Range       token.Span
 }
 
 type FunctionExpression struct {
-	Type       NodeType
-	Parameters []Node
-	Block      Node
+	Parameters []IdentifierExpression
+	Block      Block
+	Range      token.Span
+}
+
+type ArrayLiteral struct {
+	Elements []Expression
+	Range    token.Span
+}
+
+type IndexExpression struct {
+	Left    Expression
+	Bracket token.Token
+	Index   Expression
+	Range   token.Span
+}
+
+// SliceExpression is left[low:high]. Low and High are nil if omitted.
+type SliceExpression struct {
+	Left    Expression
+	Bracket token.Token
+	Low     Expression
+	High    Expression
+	Range   token.Span
 }
+
+type HashLiteral struct {
+	Brace token.Token
+	Pairs []HashPair
+	Range token.Span
+}
+
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+// Nodes record their span in Range, unless it is that of their only
+// token.
+func (n Block) Span() token.Span                { return n.Range }
+func (n Function) Span() token.Span             { return n.Range }
+func (n IfStatement) Span() token.Span          { return n.Range }
+func (n LetStatement) Span() token.Span         { return n.Range }
+func (n ReturnStatement) Span() token.Span      { return n.Range }
+func (n YieldStatement) Span() token.Span       { return n.Range }
+func (n ExpressionStatement) Span() token.Span  { return n.Range }
+func (n UnaryExpression) Span() token.Span      { return n.Range }
+func (n BinaryExpression) Span() token.Span     { return n.Range }
+func (n ParenExpression) Span() token.Span      { return n.Range }
+func (n IdentifierExpression) Span() token.Span { return n.Identifier.Span() }
+func (n LiteralExpression) Span() token.Span    { return n.Literal.Span() }
+func (n CallExpression) Span() token.Span       { return n.Range }
+func (n IfExpression) Span() token.Span         { return n.Range }
+func (n FunctionExpression) Span() token.Span   { return n.Range }
+func (n ArrayLiteral) Span() token.Span         { return n.Range }
+func (n IndexExpression) Span() token.Span      { return n.Range }
+func (n SliceExpression) Span() token.Span      { return n.Range }
+func (n HashLiteral) Span() token.Span          { return n.Range }
+
+func (n Block) Pos() token.Position                { return n.Span().Pos() }
+func (n Function) Pos() token.Position             { return n.Span().Pos() }
+func (n IfStatement) Pos() token.Position          { return n.Span().Pos() }
+func (n LetStatement) Pos() token.Position         { return n.Span().Pos() }
+func (n ReturnStatement) Pos() token.Position      { return n.Span().Pos() }
+func (n YieldStatement) Pos() token.Position       { return n.Span().Pos() }
+func (n ExpressionStatement) Pos() token.Position  { return n.Span().Pos() }
+func (n UnaryExpression) Pos() token.Position      { return n.Span().Pos() }
+func (n BinaryExpression) Pos() token.Position     { return n.Span().Pos() }
+func (n ParenExpression) Pos() token.Position      { return n.Span().Pos() }
+func (n IdentifierExpression) Pos() token.Position { return n.Span().Pos() }
+func (n LiteralExpression) Pos() token.Position    { return n.Span().Pos() }
+func (n CallExpression) Pos() token.Position       { return n.Span().Pos() }
+func (n IfExpression) Pos() token.Position         { return n.Span().Pos() }
+func (n FunctionExpression) Pos() token.Position   { return n.Span().Pos() }
+func (n ArrayLiteral) Pos() token.Position         { return n.Span().Pos() }
+func (n IndexExpression) Pos() token.Position      { return n.Span().Pos() }
+func (n SliceExpression) Pos() token.Position      { return n.Span().Pos() }
+func (n HashLiteral) Pos() token.Position          { return n.Span().Pos() }
+
+func (Block) node()                {}
+func (Function) node()             {}
+func (IfStatement) node()          {}
+func (LetStatement) node()         {}
+func (ReturnStatement) node()      {}
+func (YieldStatement) node()       {}
+func (ExpressionStatement) node()  {}
+func (UnaryExpression) node()      {}
+func (BinaryExpression) node()     {}
+func (ParenExpression) node()      {}
+func (IdentifierExpression) node() {}
+func (LiteralExpression) node()    {}
+func (CallExpression) node()       {}
+func (IfExpression) node()         {}
+func (FunctionExpression) node()   {}
+func (ArrayLiteral) node()         {}
+func (IndexExpression) node()      {}
+func (SliceExpression) node()      {}
+func (HashLiteral) node()          {}
+
+func (Block) statementNode()               {}
+func (Function) statementNode()            {}
+func (IfStatement) statementNode()         {}
+func (LetStatement) statementNode()        {}
+func (ReturnStatement) statementNode()     {}
+func (YieldStatement) statementNode()      {}
+func (ExpressionStatement) statementNode() {}
+
+func (Block) expressionNode()                {}
+func (UnaryExpression) expressionNode()      {}
+func (BinaryExpression) expressionNode()     {}
+func (ParenExpression) expressionNode()      {}
+func (IdentifierExpression) expressionNode() {}
+func (LiteralExpression) expressionNode()    {}
+func (CallExpression) expressionNode()       {}
+func (IfExpression) expressionNode()         {}
+func (FunctionExpression) expressionNode()   {}
+func (ArrayLiteral) expressionNode()         {}
+func (IndexExpression) expressionNode()      {}
+func (SliceExpression) expressionNode()      {}
+func (HashLiteral) expressionNode()          {}
@@ -0,0 +1,254 @@
+package ast_test
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/tobiashort/monkey/ast"
+	"github.com/tobiashort/monkey/lexer"
+	"github.com/tobiashort/monkey/parser"
+	"github.com/tobiashort/monkey/token"
+)
+
+func parse(t *testing.T, input string) ast.Ast {
+	tokens, err := lexer.New("test.mk", input).Analyze()
+	if err != nil {
+		t.Fatal(err)
+	}
+	program, err := parser.New(tokens).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return program
+}
+
+func TestString(t *testing.T) {
+	inputs := []string{
+		`let a = 1 + 2 * 3;`,
+		`let b = (!true) == false;`,
+		`return a - -b;`,
+		`fn add(x, y) { return x + y; }`,
+		`if (a < b) { a; } else { b; }`,
+		`if a { puts("a"); }`,
+		`let c = if (a) { yield [1, 2.5][0]; } else { yield {"k": null}["k"]; };`,
+		`let d = { let e = xs[1:]; yield e[:2]; };`,
+		`fn(x) { return x; }(1);`,
+		`({"a": 1});`,
+		`{ let f = 0x_FF; }`,
+	}
+	expected := []string{
+		`let a = (1 + (2 * 3));`,
+		`let b = ((!true) == false);`,
+		`return (a - (-b));`,
+		`fn add(x, y) { return (x + y); }`,
+		`if (a < b) { a; } else { b; }`,
+		`if a { puts("a"); }`,
+		`let c = if a { yield [1, 2.5][0]; } else { yield {"k": null}["k"]; };`,
+		`let d = { let e = xs[1:]; yield e[:2]; };`,
+		`fn(x) { return x; }(1);`,
+		`({"a": 1});`,
+		`{ let f = 0x_FF; }`,
+	}
+
+	for i, input := range inputs {
+		actual := parse(t, input).String()
+		if actual != expected[i] {
+			t.Fatalf("Expected: %s, Got: %s", expected[i], actual)
+		}
+		if again := parse(t, actual).String(); again != actual {
+			t.Fatalf("Expected %s to print the same when parsed again, got %s", actual, again)
+		}
+	}
+}
+
+// withoutPositions returns a copy of v in which every span and every
+// token position is zero, for comparing trees parsed from different
+// sources.
+func withoutPositions(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(withoutPositions(v.Elem()))
+		return c
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(withoutPositions(v.Elem()))
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := range v.Len() {
+			c.Index(i).Set(withoutPositions(v.Index(i)))
+		}
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		switch v.Type() {
+		case reflect.TypeFor[token.Span]():
+			c.SetZero()
+		case reflect.TypeFor[token.Token]():
+			tok := c.Addr().Interface().(*token.Token)
+			tok.File, tok.Line, tok.Column, tok.Offset, tok.End = "", 0, 0, 0, 0
+		default:
+			for i := range c.NumField() {
+				c.Field(i).Set(withoutPositions(c.Field(i)))
+			}
+		}
+		return c
+	default:
+		return v
+	}
+}
+
+func TestString2(t *testing.T) {
+	inputs := []string{
+		`let a = 1 + 2 * 3;`,
+		`let b = ((!true) == false);`,
+		`let c = (a)(b, (c - -d))[(e)];`,
+		`if (a < b) { yield ({"k": (1)}); } else { return fn(x) { return -(x); }; }`,
+	}
+	withoutParens := func(node ast.Node) ast.Node {
+		if paren, ok := node.(ast.ParenExpression); ok {
+			return paren.Expression
+		}
+		return node
+	}
+	same := func(a, b ast.Ast) bool {
+		return reflect.DeepEqual(withoutPositions(reflect.ValueOf(a)).Interface(), withoutPositions(reflect.ValueOf(b)).Interface())
+	}
+
+	for _, input := range inputs {
+		program := parse(t, input)
+		again := parse(t, program.String())
+		if !same(ast.RewriteAst(program, withoutParens), ast.RewriteAst(again, withoutParens)) {
+			t.Fatalf("Expected %s to parse to the tree of %s, up to parentheses", program, input)
+		}
+		if !same(parse(t, again.String()), again) {
+			t.Fatalf("Expected %s to parse to the same tree again", again)
+		}
+	}
+}
+
+func TestPos(t *testing.T) {
+	program := parse(t, "let a = 1;\n  a + 2;")
+
+	expected := []token.Position{
+		{File: "test.mk", Line: 1, Column: 1},
+		{File: "test.mk", Line: 2, Column: 3},
+	}
+	for i, stmt := range program {
+		if pos := stmt.Pos(); pos != expected[i] {
+			t.Fatalf("Expected: %s, Got: %s", expected[i], pos)
+		}
+	}
+	if pos := program[1].(ast.ExpressionStatement).Expression.(ast.BinaryExpression).Right.Pos(); pos.String() != "test.mk:2:7" {
+		t.Fatalf("Expected: test.mk:2:7, Got: %s", pos)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	program := parse(t, `let a = f(b, [c]); fn g(d) { if (d) { yield e; } else { yield fn(h) { return h; }; } }`)
+
+	var idents []string
+	ast.InspectAst(program, func(node ast.Node) bool {
+		if _, ok := node.(ast.FunctionExpression); ok {
+			return false
+		}
+		if ident, ok := node.(ast.IdentifierExpression); ok {
+			idents = append(idents, ident.Identifier.Literal)
+		}
+		return true
+	})
+
+	expected := "f b c d d e"
+	if actual := strings.Join(idents, " "); actual != expected {
+		t.Fatalf("Expected: %s, Got: %s", expected, actual)
+	}
+}
+
+type depthVisitor struct {
+	depth    *int
+	maxDepth *int
+}
+
+func (v depthVisitor) Visit(node ast.Node) ast.Visitor {
+	if node == nil {
+		*v.depth--
+		return nil
+	}
+	*v.depth++
+	*v.maxDepth = max(*v.maxDepth, *v.depth)
+	return v
+}
+
+func TestWalk(t *testing.T) {
+	depth, maxDepth := 0, 0
+	ast.Walk(depthVisitor{&depth, &maxDepth}, parse(t, `{ let a = -(1 + b[2]); }`)[0])
+
+	if depth != 0 {
+		t.Fatalf("Expected every visit to be closed with nil, %d left open", depth)
+	}
+	// Block, LetStatement, UnaryExpression, ParenExpression,
+	// BinaryExpression, IndexExpression, LiteralExpression
+	if maxDepth != 7 {
+		t.Fatalf("Expected: 7, Got: %d", maxDepth)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	program := parse(t, `let a = 1 + 2 * 3; puts(a); fn f(x) { puts(x); return x + (4 - 1); }`)
+
+	// fold integer arithmetic and drop calls to puts
+	rewritten := ast.RewriteAst(program, func(node ast.Node) ast.Node {
+		switch n := node.(type) {
+		case ast.ExpressionStatement:
+			if call, ok := n.Expression.(ast.CallExpression); ok && call.Function.String() == "puts" {
+				return nil
+			}
+		case ast.BinaryExpression:
+			left, lok := n.Left.(ast.LiteralExpression)
+			right, rok := n.Right.(ast.LiteralExpression)
+			if !lok || !rok || left.Literal.Type != token.INT || right.Literal.Type != token.INT {
+				return n
+			}
+			l, _ := strconv.ParseInt(left.Literal.Literal, 0, 64)
+			r, _ := strconv.ParseInt(right.Literal.Literal, 0, 64)
+			var v int64
+			switch n.Operator.Type {
+			case token.PLUS:
+				v = l + r
+			case token.MINUS:
+				v = l - r
+			case token.ASTERISK:
+				v = l * r
+			default:
+				return n
+			}
+			lit := left.Literal
+			lit.Literal = strconv.FormatInt(v, 10)
+			lit.Offset, lit.End = n.Span().Offset, n.Span().End
+			return ast.LiteralExpression{Literal: lit}
+		}
+		return node
+	})
+
+	expected := "let a = 7;\nfn f(x) { return (x + 3); }"
+	if actual := rewritten.String(); actual != expected {
+		t.Fatalf("Expected: %s, Got: %s", expected, actual)
+	}
+	expected = "let a = (1 + (2 * 3));\nputs(a);\nfn f(x) { puts(x); return (x + (4 - 1)); }"
+	if actual := program.String(); actual != expected {
+		t.Fatalf("Expected original to be unchanged: %s, Got: %s", expected, actual)
+	}
+}
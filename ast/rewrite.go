@@ -0,0 +1,149 @@
+package ast
+
+import "fmt"
+
+// Rewrite returns a copy of the tree rooted at node in which every node
+// is replaced by what f returns for it, bottom up: f sees a node after
+// its children have been rewritten. f returns its argument to keep a
+// node, or nil to remove a statement from its program or block.
+//
+// f must return a statement for a statement, an expression for an
+// expression, an IdentifierExpression for a function parameter and a
+// Block for the body or branch of a function or if. Rewrite panics
+// otherwise, as the result would not be a valid tree.
+func Rewrite(node Node, f func(Node) Node) Node {
+	switch n := node.(type) {
+	case Block:
+		n.Ast = RewriteAst(n.Ast, f)
+		node = n
+	case Function:
+		n.Parameters = rewriteParameters(n.Parameters, f)
+		n.Block = rewriteBlock(n.Block, f)
+		node = n
+	case IfStatement:
+		n.Condition = rewriteExpression(n.Condition, f)
+		n.Consequence = rewriteBlock(n.Consequence, f)
+		if n.Alternative != nil {
+			alt := rewriteBlock(*n.Alternative, f)
+			n.Alternative = &alt
+		}
+		node = n
+	case LetStatement:
+		n.Expression = rewriteExpression(n.Expression, f)
+		node = n
+	case ReturnStatement:
+		n.Expression = rewriteExpression(n.Expression, f)
+		node = n
+	case YieldStatement:
+		n.Expression = rewriteExpression(n.Expression, f)
+		node = n
+	case ExpressionStatement:
+		n.Expression = rewriteExpression(n.Expression, f)
+		node = n
+	case UnaryExpression:
+		n.Right = rewriteExpression(n.Right, f)
+		node = n
+	case BinaryExpression:
+		n.Left = rewriteExpression(n.Left, f)
+		n.Right = rewriteExpression(n.Right, f)
+		node = n
+	case ParenExpression:
+		n.Expression = rewriteExpression(n.Expression, f)
+		node = n
+	case CallExpression:
+		n.Function = rewriteExpression(n.Function, f)
+		n.Parameters = rewriteExpressions(n.Parameters, f)
+		node = n
+	case IfExpression:
+		n.Condition = rewriteExpression(n.Condition, f)
+		n.Consequence = rewriteBlock(n.Consequence, f)
+		n.Alternative = rewriteBlock(n.Alternative, f)
+		node = n
+	case FunctionExpression:
+		n.Parameters = rewriteParameters(n.Parameters, f)
+		n.Block = rewriteBlock(n.Block, f)
+		node = n
+	case ArrayLiteral:
+		n.Elements = rewriteExpressions(n.Elements, f)
+		node = n
+	case IndexExpression:
+		n.Left = rewriteExpression(n.Left, f)
+		n.Index = rewriteExpression(n.Index, f)
+		node = n
+	case SliceExpression:
+		n.Left = rewriteExpression(n.Left, f)
+		n.Low = rewriteExpression(n.Low, f)
+		n.High = rewriteExpression(n.High, f)
+		node = n
+	case HashLiteral:
+		pairs := make([]HashPair, len(n.Pairs))
+		for i, pair := range n.Pairs {
+			pairs[i] = HashPair{
+				Key:   rewriteExpression(pair.Key, f),
+				Value: rewriteExpression(pair.Value, f),
+			}
+		}
+		n.Pairs = pairs
+		node = n
+	}
+	return f(node)
+}
+
+// RewriteAst rewrites every statement of a program in order, see
+// Rewrite.
+func RewriteAst(program Ast, f func(Node) Node) Ast {
+	rewritten := make(Ast, 0, len(program))
+	for _, stmt := range program {
+		r := Rewrite(stmt, f)
+		if r == nil {
+			continue
+		}
+		if s, ok := r.(Statement); ok {
+			rewritten = append(rewritten, s)
+		} else {
+			panic(fmt.Sprintf("ast: statement rewritten to %T", r))
+		}
+	}
+	return rewritten
+}
+
+// rewriteExpression rewrites expr, which may be nil for an optional one.
+func rewriteExpression(expr Expression, f func(Node) Node) Expression {
+	if expr == nil {
+		return nil
+	}
+	r := Rewrite(expr, f)
+	if e, ok := r.(Expression); ok {
+		return e
+	}
+	panic(fmt.Sprintf("ast: expression rewritten to %T", r))
+}
+
+func rewriteExpressions(exprs []Expression, f func(Node) Node) []Expression {
+	rewritten := make([]Expression, len(exprs))
+	for i, expr := range exprs {
+		rewritten[i] = rewriteExpression(expr, f)
+	}
+	return rewritten
+}
+
+func rewriteParameters(params []IdentifierExpression, f func(Node) Node) []IdentifierExpression {
+	rewritten := make([]IdentifierExpression, len(params))
+	for i, param := range params {
+		r := Rewrite(param, f)
+		if ident, ok := r.(IdentifierExpression); ok {
+			rewritten[i] = ident
+		} else {
+			panic(fmt.Sprintf("ast: parameter rewritten to %T", r))
+		}
+	}
+	return rewritten
+}
+
+func rewriteBlock(block Block, f func(Node) Node) Block {
+	r := Rewrite(block, f)
+	if b, ok := r.(Block); ok {
+		return b
+	}
+	panic(fmt.Sprintf("ast: block rewritten to %T", r))
+}
@@ -0,0 +1,132 @@
+package ast
+
+import "strings"
+
+// The String methods print nodes as canonical source: one statement per
+// line at the top level, blocks on a single line, and every unary and
+// binary expression in parentheses, so that precedence is explicit.
+// ParenExpressions print as their expression. Parsing the output yields
+// the same tree up to spans, except that every unary and binary
+// expression comes back in a ParenExpression; parsing the output of that
+// tree yields it again.
+
+func (a Ast) String() string {
+	lines := make([]string, len(a))
+	for i, stmt := range a {
+		lines[i] = stmt.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (n Block) String() string {
+	if len(n.Ast) == 0 {
+		return "{}"
+	}
+	stmts := make([]string, len(n.Ast))
+	for i, stmt := range n.Ast {
+		stmts[i] = stmt.String()
+	}
+	return "{ " + strings.Join(stmts, " ") + " }"
+}
+
+func (n Function) String() string {
+	return "fn " + n.Identifier.Literal + "(" + join(n.Parameters) + ") " + n.Block.String()
+}
+
+func (n IfStatement) String() string {
+	s := "if " + n.Condition.String() + " " + n.Consequence.String()
+	if n.Alternative != nil {
+		s += " else " + n.Alternative.String()
+	}
+	return s
+}
+
+func (n LetStatement) String() string {
+	return "let " + n.Identifier.Literal + " = " + n.Expression.String() + ";"
+}
+
+func (n ReturnStatement) String() string {
+	return "return " + n.Expression.String() + ";"
+}
+
+func (n YieldStatement) String() string {
+	return "yield " + n.Expression.String() + ";"
+}
+
+func (n ExpressionStatement) String() string {
+	s := n.Expression.String()
+	if strings.HasPrefix(s, "{") || strings.HasPrefix(s, "if ") {
+		// would be read as a block or if statement otherwise
+		s = "(" + s + ")"
+	}
+	return s + ";"
+}
+
+func (n UnaryExpression) String() string {
+	return "(" + n.Operator.Literal + n.Right.String() + ")"
+}
+
+func (n BinaryExpression) String() string {
+	return "(" + n.Left.String() + " " + n.Operator.Literal + " " + n.Right.String() + ")"
+}
+
+// String leaves the parentheses out, as operators print their own.
+func (n ParenExpression) String() string {
+	return n.Expression.String()
+}
+
+func (n IdentifierExpression) String() string {
+	return n.Identifier.Literal
+}
+
+func (n LiteralExpression) String() string {
+	return n.Literal.Literal
+}
+
+func (n CallExpression) String() string {
+	return n.Function.String() + "(" + join(n.Parameters) + ")"
+}
+
+func (n IfExpression) String() string {
+	return "if " + n.Condition.String() + " " + n.Consequence.String() + " else " + n.Alternative.String()
+}
+
+func (n FunctionExpression) String() string {
+	return "fn(" + join(n.Parameters) + ") " + n.Block.String()
+}
+
+func (n ArrayLiteral) String() string {
+	return "[" + join(n.Elements) + "]"
+}
+
+func (n IndexExpression) String() string {
+	return n.Left.String() + "[" + n.Index.String() + "]"
+}
+
+func (n SliceExpression) String() string {
+	s := n.Left.String() + "["
+	if n.Low != nil {
+		s += n.Low.String()
+	}
+	s += ":"
+	if n.High != nil {
+		s += n.High.String()
+	}
+	return s + "]"
+}
+
+func (n HashLiteral) String() string {
+	pairs := make([]string, len(n.Pairs))
+	for i, pair := range n.Pairs {
+		pairs[i] = pair.Key.String() + ": " + pair.Value.String()
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+func join[T Node](nodes []T) string {
+	s := make([]string, len(nodes))
+	for i, node := range nodes {
+		s[i] = node.String()
+	}
+	return strings.Join(s, ", ")
+}
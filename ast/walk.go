@@ -0,0 +1,115 @@
+package ast
+
+// Visitor is called by Walk for every node. If Visit returns a non-nil
+// visitor w, Walk visits the children of node with w, and then calls
+// w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the tree rooted at node in depth-first order, starting
+// with v.Visit(node).
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case Block:
+		walkStatements(v, n.Ast)
+	case Function:
+		walkNodes(v, n.Parameters)
+		Walk(v, n.Block)
+	case IfStatement:
+		Walk(v, n.Condition)
+		Walk(v, n.Consequence)
+		if n.Alternative != nil {
+			Walk(v, *n.Alternative)
+		}
+	case LetStatement:
+		Walk(v, n.Expression)
+	case ReturnStatement:
+		Walk(v, n.Expression)
+	case YieldStatement:
+		Walk(v, n.Expression)
+	case ExpressionStatement:
+		Walk(v, n.Expression)
+	case UnaryExpression:
+		Walk(v, n.Right)
+	case BinaryExpression:
+		Walk(v, n.Left)
+		Walk(v, n.Right)
+	case ParenExpression:
+		Walk(v, n.Expression)
+	case IdentifierExpression, LiteralExpression:
+		// no children
+	case CallExpression:
+		Walk(v, n.Function)
+		walkNodes(v, n.Parameters)
+	case IfExpression:
+		Walk(v, n.Condition)
+		Walk(v, n.Consequence)
+		Walk(v, n.Alternative)
+	case FunctionExpression:
+		walkNodes(v, n.Parameters)
+		Walk(v, n.Block)
+	case ArrayLiteral:
+		walkNodes(v, n.Elements)
+	case IndexExpression:
+		Walk(v, n.Left)
+		Walk(v, n.Index)
+	case SliceExpression:
+		Walk(v, n.Left)
+		if n.Low != nil {
+			Walk(v, n.Low)
+		}
+		if n.High != nil {
+			Walk(v, n.High)
+		}
+	case HashLiteral:
+		for _, pair := range n.Pairs {
+			Walk(v, pair.Key)
+			Walk(v, pair.Value)
+		}
+	}
+
+	v.Visit(nil)
+}
+
+// WalkAst walks every statement of a program in order.
+func WalkAst(v Visitor, program Ast) {
+	walkStatements(v, program)
+}
+
+func walkStatements(v Visitor, stmts Ast) {
+	for _, stmt := range stmts {
+		Walk(v, stmt)
+	}
+}
+
+func walkNodes[T Node](v Visitor, nodes []T) {
+	for _, node := range nodes {
+		Walk(v, node)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the tree rooted at node in depth-first order,
+// calling f for every node, and with nil after the children of a node.
+// The children of a node are skipped if f returns false for it.
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// InspectAst inspects every statement of a program in order.
+func InspectAst(program Ast, f func(Node) bool) {
+	WalkAst(inspector(f), program)
+}
@@ -0,0 +1,97 @@
+package diag
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tobiashort/monkey/token"
+)
+
+type Severity = string
+
+const (
+	ERROR   = "error"
+	WARNING = "warning"
+	NOTE    = "note"
+)
+
+// Code identifies the kind of a diagnostic. Codes are stable, so that
+// tools can rely on them, while messages may change.
+type Code = string
+
+const (
+	// Lexer
+	ILLEGAL_CHARACTER    = "illegal-character"
+	UNTERMINATED_STRING  = "unterminated-string"
+	UNTERMINATED_COMMENT = "unterminated-comment"
+	INVALID_ESCAPE       = "invalid-escape"
+	INVALID_NUMBER       = "invalid-number"
+	READ_ERROR           = "read-error"
+
+	// Parser. Blocks, parameter lists and argument lists left open at the
+	// end of input have codes of their own; any other bracket or brace
+	// left open is an UNEXPECTED_TOKEN, the end of input where it should
+	// have been closed.
+	UNEXPECTED_TOKEN    = "unexpected-token"
+	UNCLOSED_BLOCK      = "unclosed-block"
+	UNCLOSED_PARAMETERS = "unclosed-parameters"
+	UNCLOSED_ARGUMENTS  = "unclosed-arguments"
+	MISSING_ELSE        = "missing-else"
+)
+
+// Diagnostic is a problem found in the source, at Span.
+type Diagnostic struct {
+	Severity Severity
+	Code     Code
+	Span     token.Span
+	Message  string
+	Notes    []Note
+}
+
+// Note adds information to a diagnostic, optionally pointing at another
+// part of the source. Span is the zero Span if it does not.
+type Note struct {
+	Span    token.Span
+	Message string
+}
+
+// Errorf returns an ERROR diagnostic at span.
+func Errorf(code Code, span token.Span, format string, a ...any) Diagnostic {
+	return Diagnostic{
+		Severity: ERROR,
+		Code:     code,
+		Span:     span,
+		Message:  fmt.Sprintf(format, a...),
+	}
+}
+
+// WithNote returns d with a note appended.
+func (d Diagnostic) WithNote(span token.Span, format string, a ...any) Diagnostic {
+	d.Notes = append(d.Notes[:len(d.Notes):len(d.Notes)], Note{Span: span, Message: fmt.Sprintf(format, a...)})
+	return d
+}
+
+// Error returns the diagnostic as a single line, prefixed with its
+// position.
+func (d Diagnostic) Error() string {
+	return d.Span.Pos().String() + ": " + d.Message
+}
+
+// List is a list of diagnostics, in the order of the source.
+type List []Diagnostic
+
+func (l List) Error() string {
+	messages := make([]string, len(l))
+	for i, d := range l {
+		messages[i] = d.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+func (l List) Unwrap() []error {
+	errs := make([]error, len(l))
+	for i, d := range l {
+		errs[i] = d
+	}
+	return errs
+}
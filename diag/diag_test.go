@@ -0,0 +1,158 @@
+package diag_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/tobiashort/monkey/diag"
+	"github.com/tobiashort/monkey/token"
+	"github.com/tobiashort/utils-go/strings"
+)
+
+func test(t *testing.T, d diag.Diagnostic, source diag.Source, expected string) {
+	var b bytes.Buffer
+	diag.Render(&b, d, source)
+	if b.String() != expected {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", expected, b.String())
+	}
+}
+
+func TestRender1(t *testing.T) {
+	source := "let a = ;"
+	d := diag.Errorf(diag.UNEXPECTED_TOKEN, token.Span{File: "test.mk", Line: 1, Column: 9, Offset: 8, End: 9}, "expected expression, got %q", ";")
+	expected := strings.Dedent(`test.mk:1:9: error: expected expression, got ";" [unexpected-token]
+	                           | 1 | let a = ;
+	                           |   |         ^
+	                           |`)
+	test(t, d, diag.Text(source), expected)
+}
+
+func TestRender2(t *testing.T) {
+	// tabs are kept and multi-byte characters take a single column
+	source := "let a = 1;\n\tlet s = \"ü\\q\";\nputs(s);"
+	d := diag.Errorf(diag.INVALID_ESCAPE, token.Span{File: "test.mk", Line: 2, Column: 12, Offset: 23, End: 25}, "invalid escape sequence")
+	expected := "test.mk:2:12: error: invalid escape sequence [invalid-escape]\n" +
+		" 2 | \tlet s = \"ü\\q\";\n" +
+		"   | \t          ^^\n"
+	test(t, d, diag.Text(source), expected)
+}
+
+func TestRender3(t *testing.T) {
+	source := "let f = fn(x) {\n  x"
+	d := diag.Errorf(diag.UNCLOSED_BLOCK, token.Span{File: "test.mk", Line: 2, Column: 4, Offset: 19, End: 19}, "unclosed block").
+		WithNote(token.Span{File: "test.mk", Line: 1, Column: 15, Offset: 14, End: 15}, "block opened here").
+		WithNote(token.Span{}, "blocks are closed with %q", "}")
+	expected := strings.Dedent(`test.mk:2:4: error: unclosed block [unclosed-block]
+	                           | 2 |   x
+	                           |   |    ^
+	                           |test.mk:1:15: note: block opened here
+	                           | 1 | let f = fn(x) {
+	                           |   |               ^
+	                           |  = note: blocks are closed with "}"
+	                           |`)
+	test(t, d, diag.Text(source), expected)
+}
+
+func TestRender4(t *testing.T) {
+	d := diag.Errorf(diag.READ_ERROR, token.Span{File: "test.mk", Line: 1, Column: 6, Offset: 5, End: 6}, "broken pipe")
+	expected := "test.mk:1:6: error: broken pipe [read-error]\n"
+	// the source is not available
+	test(t, d, nil, expected)
+}
+
+func TestList(t *testing.T) {
+	l := diag.List{
+		diag.Errorf(diag.UNEXPECTED_TOKEN, token.Span{File: "test.mk", Line: 1, Column: 9}, "a"),
+		diag.Errorf(diag.MISSING_ELSE, token.Span{File: "test.mk", Line: 3, Column: 1}, "b"),
+	}
+	if l.Error() != "test.mk:1:9: a\ntest.mk:3:1: b" {
+		t.Fatalf("Got: %s", l.Error())
+	}
+	if len(l.Unwrap()) != 2 {
+		t.Fatalf("Expected 2 errors, got %d", len(l.Unwrap()))
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	l := diag.List{
+		diag.Errorf(diag.UNEXPECTED_TOKEN, token.Span{File: "test.mk", Line: 1, Column: 9, Offset: 8, End: 9}, "expected expression, got %q", ";"),
+		diag.Errorf(diag.UNCLOSED_BLOCK, token.Span{File: "test.mk", Line: 2, Column: 4, Offset: 19, End: 19}, "unclosed block").
+			WithNote(token.Span{File: "test.mk", Line: 1, Column: 15, Offset: 14, End: 15}, "block opened here").
+			WithNote(token.Span{}, "blocks are closed with %q", "}"),
+	}
+	expected := `{"severity":"error","code":"unexpected-token","message":"expected expression, got \";\"","location":{"file":"test.mk","line":1,"column":9,"offset":8,"end":9}}` + "\n" +
+		`{"severity":"error","code":"unclosed-block","message":"unclosed block","location":{"file":"test.mk","line":2,"column":4,"offset":19,"end":19},` +
+		`"notes":[{"message":"block opened here","location":{"file":"test.mk","line":1,"column":15,"offset":14,"end":15}},{"message":"blocks are closed with \"}\""}]}` + "\n"
+	var b bytes.Buffer
+	if err := diag.WriteJSON(&b, l); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != expected {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", expected, b.String())
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	l := diag.List{
+		diag.Errorf(diag.UNEXPECTED_TOKEN, token.Span{File: "test.mk", Line: 1, Column: 9, Offset: 8, End: 9}, "a"),
+		diag.Errorf(diag.UNEXPECTED_TOKEN, token.Span{File: "test.mk", Line: 2, Column: 1, Offset: 10, End: 13}, "b").
+			WithNote(token.Span{}, "c"),
+		diag.Errorf(diag.UNCLOSED_PARAMETERS, token.Span{File: "test.mk", Line: 3, Column: 5, Offset: 20, End: 21}, "d"),
+	}
+	var b bytes.Buffer
+	if err := diag.WriteSARIF(&b, l, "monkey"); err != nil {
+		t.Fatal(err)
+	}
+
+	var log struct {
+		Version string
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Name  string
+					Rules []struct{ ID string }
+				}
+			}
+			Results []struct {
+				RuleID    string
+				Level     string
+				Message   struct{ Text string }
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct{ URI string }
+						Region           struct{ StartLine, StartColumn, ByteOffset, ByteLength int }
+					}
+				}
+				RelatedLocations []struct {
+					Message struct{ Text string }
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(b.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("Unexpected log:\n%s", b.String())
+	}
+	run := log.Runs[0]
+	if run.Tool.Driver.Name != "monkey" || len(run.Tool.Driver.Rules) != 2 ||
+		run.Tool.Driver.Rules[0].ID != "unexpected-token" || run.Tool.Driver.Rules[1].ID != "unclosed-parameters" {
+		t.Fatalf("Unexpected tool: %+v", run.Tool)
+	}
+	if len(run.Results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(run.Results))
+	}
+	result := run.Results[1]
+	if result.RuleID != "unexpected-token" || result.Level != "error" || result.Message.Text != "b" {
+		t.Fatalf("Unexpected result: %+v", result)
+	}
+	if loc := result.Locations[0].PhysicalLocation; loc.ArtifactLocation.URI != "test.mk" ||
+		loc.Region.StartLine != 2 || loc.Region.StartColumn != 1 || loc.Region.ByteOffset != 10 || loc.Region.ByteLength != 3 {
+		t.Fatalf("Unexpected location: %+v", loc)
+	}
+	if len(result.RelatedLocations) != 1 || result.RelatedLocations[0].Message.Text != "c" {
+		t.Fatalf("Unexpected related locations: %+v", result.RelatedLocations)
+	}
+}
@@ -0,0 +1,69 @@
+package diag
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/tobiashort/monkey/token"
+)
+
+type jsonDiagnostic struct {
+	Severity Severity   `json:"severity"`
+	Code     Code       `json:"code"`
+	Message  string     `json:"message"`
+	Location location   `json:"location"`
+	Notes    []jsonNote `json:"notes,omitempty"`
+}
+
+type jsonNote struct {
+	Message  string    `json:"message"`
+	Location *location `json:"location,omitempty"`
+}
+
+// location is a span as it is written to JSON. Line and Column count
+// runes from 1, Offset and End are byte offsets.
+type location struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Offset int    `json:"offset"`
+	End    int    `json:"end"`
+}
+
+func newLocation(span token.Span) location {
+	return location{
+		File:   span.File,
+		Line:   span.Line,
+		Column: span.Column,
+		Offset: span.Offset,
+		End:    span.End,
+	}
+}
+
+// WriteJSON writes every diagnostic in l as a JSON object on a line of
+// its own.
+//
+//	{"severity":"error","code":"unexpected-token","message":"expected expression, got \";\"","location":{"file":"test.mk","line":1,"column":9,"offset":8,"end":9}}
+func WriteJSON(w io.Writer, l List) error {
+	enc := json.NewEncoder(w)
+	for _, d := range l {
+		jd := jsonDiagnostic{
+			Severity: d.Severity,
+			Code:     d.Code,
+			Message:  d.Message,
+			Location: newLocation(d.Span),
+		}
+		for _, note := range d.Notes {
+			jn := jsonNote{Message: note.Message}
+			if note.Span != (token.Span{}) {
+				loc := newLocation(note.Span)
+				jn.Location = &loc
+			}
+			jd.Notes = append(jd.Notes, jn)
+		}
+		if err := enc.Encode(jd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package diag
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tobiashort/monkey/token"
+)
+
+// Source gives the lines of source that diagnostics are shown in.
+type Source interface {
+	// Line returns line n, counted from 1, without its line break, and
+	// false if it is not available.
+	Line(n int) (string, bool)
+}
+
+// Text is a Source over a whole text held in memory.
+type Text string
+
+func (t Text) Line(n int) (string, bool) {
+	text := string(t)
+	for i := 1; i < n; i++ {
+		j := strings.IndexByte(text, '\n')
+		if j < 0 {
+			return "", false
+		}
+		text = text[j+1:]
+	}
+	if n < 1 {
+		return "", false
+	}
+	if j := strings.IndexByte(text, '\n'); j >= 0 {
+		text = text[:j]
+	}
+	return text, true
+}
+
+// Render writes d the way compilers print errors: its position,
+// severity, message and code, followed by the line of source it refers
+// to with its span underlined by carets, and then its notes. The snippet
+// is left out if source is nil or does not have the line.
+//
+//	test.mk:1:9: error: expected expression, got ";" [unexpected-token]
+//	  1 | let a = ;
+//	    |         ^
+func Render(w io.Writer, d Diagnostic, source Source) {
+	fmt.Fprintf(w, "%s: %s: %s [%s]\n", d.Span.Pos(), d.Severity, d.Message, d.Code)
+	snippet(w, d.Span, source)
+	for _, note := range d.Notes {
+		if note.Span == (token.Span{}) {
+			fmt.Fprintf(w, "  = note: %s\n", note.Message)
+		} else {
+			fmt.Fprintf(w, "%s: note: %s\n", note.Span.Pos(), note.Message)
+			snippet(w, note.Span, source)
+		}
+	}
+}
+
+// RenderAll renders every diagnostic in l, see Render.
+func RenderAll(w io.Writer, l List, source Source) {
+	for _, d := range l {
+		Render(w, d, source)
+	}
+}
+
+func snippet(w io.Writer, span token.Span, source Source) {
+	if source == nil || span.Column < 1 {
+		return
+	}
+	line, ok := source.Line(span.Line)
+	if !ok {
+		return
+	}
+	line = strings.TrimSuffix(line, "\r")
+
+	// the column counts runes, find the byte it starts at
+	start := 0
+	for i := 1; i < span.Column && start < len(line); i++ {
+		_, size := utf8.DecodeRuneInString(line[start:])
+		start += size
+	}
+	// keep tabs, so that the carets line up with the source
+	padding := strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return r
+		}
+		return ' '
+	}, line[:start])
+	end := min(start+max(span.End-span.Offset, 0), len(line))
+	width := max(utf8.RuneCountInString(line[start:end]), 1)
+
+	number := strconv.Itoa(span.Line)
+	gutter := strings.Repeat(" ", len(number))
+	fmt.Fprintf(w, " %s | %s\n", number, line)
+	fmt.Fprintf(w, " %s | %s%s\n", gutter, padding, strings.Repeat("^", width))
+}
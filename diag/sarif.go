@@ -0,0 +1,124 @@
+package diag
+
+import (
+	"encoding/json"
+	"io"
+	"slices"
+
+	"github.com/tobiashort/monkey/token"
+)
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool       sarifTool     `json:"tool"`
+	ColumnKind string        `json:"columnKind"`
+	Results    []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID           string          `json:"ruleId"`
+	Level            string          `json:"level"`
+	Message          sarifMessage    `json:"message"`
+	Locations        []sarifLocation `json:"locations"`
+	RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	Message          *sarifMessage          `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	ByteOffset  int `json:"byteOffset"`
+	ByteLength  int `json:"byteLength"`
+}
+
+func newSarifPhysicalLocation(span token.Span) *sarifPhysicalLocation {
+	return &sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{URI: span.File},
+		Region: sarifRegion{
+			StartLine:   span.Line,
+			StartColumn: span.Column,
+			ByteOffset:  span.Offset,
+			ByteLength:  span.End - span.Offset,
+		},
+	}
+}
+
+// WriteSARIF writes l as a SARIF 2.1.0 log with a single run of the tool
+// named tool. Codes are used as rule ids, and notes become related
+// locations.
+func WriteSARIF(w io.Writer, l List, tool string) error {
+	run := sarifRun{
+		Tool:       sarifTool{Driver: sarifDriver{Name: tool, Rules: []sarifRule{}}},
+		ColumnKind: "unicodeCodePoints",
+		Results:    []sarifResult{},
+	}
+	var codes []Code
+	for _, d := range l {
+		if !slices.Contains(codes, d.Code) {
+			codes = append(codes, d.Code)
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: d.Code})
+		}
+		result := sarifResult{
+			RuleID:    d.Code,
+			Level:     d.Severity,
+			Message:   sarifMessage{Text: d.Message},
+			Locations: []sarifLocation{{PhysicalLocation: newSarifPhysicalLocation(d.Span)}},
+		}
+		for _, note := range d.Notes {
+			related := sarifLocation{Message: &sarifMessage{Text: note.Message}}
+			if note.Span != (token.Span{}) {
+				related.PhysicalLocation = newSarifPhysicalLocation(note.Span)
+			}
+			result.RelatedLocations = append(result.RelatedLocations, related)
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{run},
+	})
+}
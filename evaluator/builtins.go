@@ -0,0 +1,92 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/tobiashort/monkey/object"
+)
+
+var builtins = map[string]*object.Builtin{
+	"puts": {
+		Name: "puts",
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			for _, arg := range args {
+				if s, ok := arg.(*object.String); ok {
+					fmt.Fprintln(env.Output(), s.Value)
+				} else {
+					fmt.Fprintln(env.Output(), arg.Inspect())
+				}
+			}
+			return NULL
+		},
+	},
+	"len": {
+		Name: "len",
+		Fn: func(_ *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return &object.Error{Message: fmt.Sprintf("wrong number of arguments to `len`: want=1, got=%d", len(args))}
+			}
+			switch arg := args[0].(type) {
+			case *object.Array:
+				return &object.Integer{Value: int64(len(arg.Elements))}
+			case *object.Hash:
+				return &object.Integer{Value: int64(len(arg.Pairs))}
+			case *object.String:
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+			default:
+				return &object.Error{Message: fmt.Sprintf("argument to `len` not supported, got %s", arg.Type())}
+			}
+		},
+	},
+	"int": {
+		Name: "int",
+		Fn: func(_ *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return &object.Error{Message: fmt.Sprintf("wrong number of arguments to `int`: want=1, got=%d", len(args))}
+			}
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				return arg
+			case *object.Float:
+				// float64(math.MaxInt64) rounds up to 2^63, which is out of range
+				if math.IsNaN(arg.Value) || arg.Value < math.MinInt64 || arg.Value >= math.MaxInt64 {
+					return &object.Error{Message: fmt.Sprintf("cannot convert %s to INTEGER: out of range", arg.Inspect())}
+				}
+				return &object.Integer{Value: int64(arg.Value)}
+			case *object.String:
+				if v, err := strconv.ParseInt(arg.Value, 10, 64); err != nil {
+					return &object.Error{Message: fmt.Sprintf("cannot convert %s to INTEGER", arg.Inspect())}
+				} else {
+					return &object.Integer{Value: v}
+				}
+			default:
+				return &object.Error{Message: fmt.Sprintf("argument to `int` not supported, got %s", arg.Type())}
+			}
+		},
+	},
+	"float": {
+		Name: "float",
+		Fn: func(_ *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return &object.Error{Message: fmt.Sprintf("wrong number of arguments to `float`: want=1, got=%d", len(args))}
+			}
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				return &object.Float{Value: float64(arg.Value)}
+			case *object.Float:
+				return arg
+			case *object.String:
+				if v, err := strconv.ParseFloat(arg.Value, 64); err != nil {
+					return &object.Error{Message: fmt.Sprintf("cannot convert %s to FLOAT", arg.Inspect())}
+				} else {
+					return &object.Float{Value: v}
+				}
+			default:
+				return &object.Error{Message: fmt.Sprintf("argument to `float` not supported, got %s", arg.Type())}
+			}
+		},
+	},
+}
@@ -0,0 +1,527 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/tobiashort/monkey/ast"
+	"github.com/tobiashort/monkey/object"
+	"github.com/tobiashort/monkey/token"
+)
+
+var (
+	NULL  = &object.Null{}
+	TRUE  = &object.Boolean{Value: true}
+	FALSE = &object.Boolean{Value: false}
+)
+
+// Eval runs the program in env and returns the value of the last
+// statement, or nil if that statement does not produce a value.
+//
+// A return statement leaves the innermost function, or the program
+// itself at the top level. A yield statement sets the value of the
+// innermost block expression or if expression branch; blocks and if
+// statements in statement position are transparent to it. Reaching a
+// function body or the top level with a pending yield is an error, as
+// is a block expression that completes without yielding.
+func Eval(program ast.Ast, env *object.Environment) object.Object {
+	declareFunctions(program, env)
+	var result object.Object
+	for _, node := range program {
+		result = evalStatement(node, env)
+		switch result := result.(type) {
+		case *object.ReturnValue:
+			return result.Value
+		case *object.YieldValue:
+			return newErrorAt(result.Span, "yield outside of block expression")
+		case *object.Error:
+			return result
+		}
+	}
+	return result
+}
+
+// evalStatement evaluates node in statement position, where a block
+// does not catch yields but passes them on to the enclosing expression.
+func evalStatement(node ast.Statement, env *object.Environment) object.Object {
+	if block, ok := node.(ast.Block); ok {
+		return evalBlock(block, env)
+	}
+	return eval(node, env)
+}
+
+func eval(node ast.Node, env *object.Environment) object.Object {
+	switch node := node.(type) {
+	case ast.Block:
+		return evalBlockExpression(node, env, "block expression")
+	case ast.Function:
+		// already bound by declareFunctions
+		return nil
+	case ast.IfStatement:
+		return evalIfStatement(node, env)
+	case ast.LetStatement:
+		val := eval(node.Expression, env)
+		if isAbrupt(val) {
+			return val
+		}
+		env.Set(node.Identifier.Literal, val)
+		return nil
+	case ast.ReturnStatement:
+		val := eval(node.Expression, env)
+		if isAbrupt(val) {
+			return val
+		}
+		return &object.ReturnValue{Value: val}
+	case ast.YieldStatement:
+		val := eval(node.Expression, env)
+		if isAbrupt(val) {
+			return val
+		}
+		return &object.YieldValue{Value: val, Span: node.Span()}
+	case ast.ExpressionStatement:
+		return eval(node.Expression, env)
+	case ast.UnaryExpression:
+		right := eval(node.Right, env)
+		if isAbrupt(right) {
+			return right
+		}
+		return evalUnaryExpression(node.Operator, right)
+	case ast.ParenExpression:
+		return eval(node.Expression, env)
+	case ast.BinaryExpression:
+		left := eval(node.Left, env)
+		if isAbrupt(left) {
+			return left
+		}
+		if node.Operator.Type == token.LAND || node.Operator.Type == token.LOR {
+			return evalLogicalExpression(node.Operator, left, node.Right, env)
+		}
+		right := eval(node.Right, env)
+		if isAbrupt(right) {
+			return right
+		}
+		return evalBinaryExpression(node.Operator, left, right)
+	case ast.IdentifierExpression:
+		return evalIdentifier(node, env)
+	case ast.LiteralExpression:
+		return evalLiteral(node)
+	case ast.CallExpression:
+		fn := eval(node.Function, env)
+		if isAbrupt(fn) {
+			return fn
+		}
+		args := make([]object.Object, 0, len(node.Parameters))
+		for _, p := range node.Parameters {
+			arg := eval(p, env)
+			if isAbrupt(arg) {
+				return arg
+			}
+			args = append(args, arg)
+		}
+		return applyFunction(node.Paren, fn, args, env)
+	case ast.ArrayLiteral:
+		elements := make([]object.Object, 0, len(node.Elements))
+		for _, e := range node.Elements {
+			elem := eval(e, env)
+			if isAbrupt(elem) {
+				return elem
+			}
+			elements = append(elements, elem)
+		}
+		return &object.Array{Elements: elements}
+	case ast.HashLiteral:
+		return evalHashLiteral(node, env)
+	case ast.IndexExpression:
+		left := eval(node.Left, env)
+		if isAbrupt(left) {
+			return left
+		}
+		index := eval(node.Index, env)
+		if isAbrupt(index) {
+			return index
+		}
+		return evalIndexExpression(node.Bracket, left, index)
+	case ast.SliceExpression:
+		return evalSliceExpression(node, env)
+	case ast.IfExpression:
+		return evalIfExpression(node, env)
+	case ast.FunctionExpression:
+		return newFunction("", node.Parameters, node.Block, env)
+	default:
+		return &object.Error{Message: fmt.Sprintf("cannot evaluate node of type %T", node)}
+	}
+}
+
+// evalBlock evaluates block in a new scope, so that let bindings and
+// function declarations inside it are not visible outside.
+func evalBlock(block ast.Block, env *object.Environment) object.Object {
+	return evalStatements(block.Ast, object.NewEnclosedEnvironment(env))
+}
+
+func evalStatements(nodes ast.Ast, env *object.Environment) object.Object {
+	declareFunctions(nodes, env)
+	var result object.Object
+	for _, node := range nodes {
+		result = evalStatement(node, env)
+		if result == nil {
+			continue
+		}
+		switch result.Type() {
+		case object.RETURN, object.YIELD, object.ERROR:
+			return result
+		}
+	}
+	return result
+}
+
+// declareFunctions binds every function declared in nodes before any
+// of them is evaluated, so that functions of the same scope can call
+// each other regardless of the order they are declared in.
+func declareFunctions(nodes ast.Ast, env *object.Environment) {
+	for _, node := range nodes {
+		if f, ok := node.(ast.Function); ok {
+			env.Set(f.Identifier.Literal, newFunction(f.Identifier.Literal, f.Parameters, f.Block, env))
+		}
+	}
+}
+
+func evalIfStatement(stmt ast.IfStatement, env *object.Environment) object.Object {
+	cond := eval(stmt.Condition, env)
+	if isAbrupt(cond) {
+		return cond
+	}
+	if isTruthy(cond) {
+		return evalStatement(stmt.Consequence, env)
+	}
+	if stmt.Alternative != nil {
+		return evalStatement(*stmt.Alternative, env)
+	}
+	return nil
+}
+
+func evalIfExpression(expr ast.IfExpression, env *object.Environment) object.Object {
+	cond := eval(expr.Condition, env)
+	if isAbrupt(cond) {
+		return cond
+	}
+	if isTruthy(cond) {
+		return evalBlockExpression(expr.Consequence, env, "if expression branch")
+	}
+	return evalBlockExpression(expr.Alternative, env, "if expression branch")
+}
+
+// evalBlockExpression evaluates block as an expression whose value is
+// set by a yield statement inside it.
+func evalBlockExpression(block ast.Block, env *object.Environment, what string) object.Object {
+	switch result := evalBlock(block, env).(type) {
+	case *object.YieldValue:
+		return result.Value
+	case *object.ReturnValue, *object.Error:
+		return result
+	default:
+		return newErrorAt(block.Span(), "%s did not yield a value", what)
+	}
+}
+
+func evalIdentifier(ident ast.IdentifierExpression, env *object.Environment) object.Object {
+	if val, ok := env.Get(ident.Identifier.Literal); ok {
+		return val
+	}
+	if builtin, ok := builtins[ident.Identifier.Literal]; ok {
+		return builtin
+	}
+	return newError(ident.Identifier, "identifier not found: %s", ident.Identifier.Literal)
+}
+
+func evalLiteral(lit ast.LiteralExpression) object.Object {
+	t := lit.Literal
+	switch t.Type {
+	case token.INT:
+		if v, err := strconv.ParseInt(t.Literal, 0, 64); err != nil {
+			return newError(t, "invalid integer literal %q", t.Literal)
+		} else {
+			return &object.Integer{Value: v}
+		}
+	case token.FLOAT:
+		if v, err := strconv.ParseFloat(t.Literal, 64); err != nil {
+			return newError(t, "invalid float literal %q", t.Literal)
+		} else {
+			return &object.Float{Value: v}
+		}
+	case token.STRING:
+		return &object.String{Value: t.Value}
+	case token.TRUE:
+		return TRUE
+	case token.FALSE:
+		return FALSE
+	case token.NULL:
+		return NULL
+	default:
+		return newError(t, "illegal literal type %q", t.Type)
+	}
+}
+
+func evalUnaryExpression(operator token.Token, right object.Object) object.Object {
+	switch operator.Type {
+	case token.BANG:
+		return nativeBoolToBooleanObject(!isTruthy(right))
+	case token.MINUS:
+		switch right := right.(type) {
+		case *object.Integer:
+			if right.Value == math.MinInt64 {
+				return newError(operator, "integer overflow: -(%d)", right.Value)
+			}
+			return &object.Integer{Value: -right.Value}
+		case *object.Float:
+			return &object.Float{Value: -right.Value}
+		}
+	}
+	return newError(operator, "unknown operator: %s%s", operator.Literal, right.Type())
+}
+
+// evalLogicalExpression evaluates && and ||, which only evaluate their
+// right operand if the truthiness of the left one does not already
+// decide the result.
+func evalLogicalExpression(operator token.Token, left object.Object, rightNode ast.Expression, env *object.Environment) object.Object {
+	if operator.Type == token.LAND && !isTruthy(left) {
+		return FALSE
+	}
+	if operator.Type == token.LOR && isTruthy(left) {
+		return TRUE
+	}
+	right := eval(rightNode, env)
+	if isAbrupt(right) {
+		return right
+	}
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
+func evalBinaryExpression(operator token.Token, left, right object.Object) object.Object {
+	if isNumber(left) && isNumber(right) {
+		return evalNumberBinaryExpression(operator, left, right)
+	}
+	switch operator.Type {
+	case token.EQUAL:
+		return nativeBoolToBooleanObject(object.Equal(left, right))
+	case token.NOT_EQUAL:
+		return nativeBoolToBooleanObject(!object.Equal(left, right))
+	}
+	if left.Type() != right.Type() {
+		return newError(operator, "type mismatch: %s %s %s", left.Type(), operator.Literal, right.Type())
+	}
+	switch left := left.(type) {
+	case *object.String:
+		return evalStringBinaryExpression(operator, left.Value, right.(*object.String).Value)
+	case *object.Boolean:
+		return evalBooleanBinaryExpression(operator, left.Value, right.(*object.Boolean).Value)
+	}
+	return newError(operator, "unknown operator: %s %s %s", left.Type(), operator.Literal, right.Type())
+}
+
+func evalStringBinaryExpression(operator token.Token, left, right string) object.Object {
+	switch operator.Type {
+	case token.PLUS:
+		return &object.String{Value: left + right}
+	}
+	return newError(operator, "unknown operator: %s %s %s", object.STRING, operator.Literal, object.STRING)
+}
+
+// evalBooleanBinaryExpression evaluates & and | on booleans as logical
+// operators that, unlike && and ||, always evaluate both operands.
+func evalBooleanBinaryExpression(operator token.Token, left, right bool) object.Object {
+	switch operator.Type {
+	case token.BAND:
+		return nativeBoolToBooleanObject(left && right)
+	case token.BOR:
+		return nativeBoolToBooleanObject(left || right)
+	}
+	return newError(operator, "unknown operator: %s %s %s", object.BOOLEAN, operator.Literal, object.BOOLEAN)
+}
+
+func evalHashLiteral(hash ast.HashLiteral, env *object.Environment) object.Object {
+	pairs := make(map[object.HashKey]object.HashPair, len(hash.Pairs))
+	for _, pair := range hash.Pairs {
+		key := eval(pair.Key, env)
+		if isAbrupt(key) {
+			return key
+		}
+		k, ok := hashKey(key)
+		if !ok {
+			return newError(hash.Brace, "unusable as hash key: %s", key.Type())
+		}
+		value := eval(pair.Value, env)
+		if isAbrupt(value) {
+			return value
+		}
+		pairs[k] = object.HashPair{Key: key, Value: value}
+	}
+	return &object.Hash{Pairs: pairs}
+}
+
+func evalIndexExpression(bracket token.Token, left, index object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		return evalArrayIndexExpression(bracket, left, index)
+	case *object.Hash:
+		return evalHashIndexExpression(bracket, left, index)
+	default:
+		return newError(bracket, "index operator not supported: %s", left.Type())
+	}
+}
+
+// evalHashIndexExpression looks up index in hash, yielding null if the
+// key is not present.
+func evalHashIndexExpression(bracket token.Token, hash *object.Hash, index object.Object) object.Object {
+	key, ok := hashKey(index)
+	if !ok {
+		return newError(bracket, "unusable as hash key: %s", index.Type())
+	}
+	if pair, ok := hash.Pairs[key]; ok {
+		return pair.Value
+	}
+	return NULL
+}
+
+// hashKey returns the key of obj in a hash, or false if it cannot be
+// one. NaN cannot, as it is not Equal to itself.
+func hashKey(obj object.Object) (object.HashKey, bool) {
+	if f, ok := obj.(*object.Float); ok && math.IsNaN(f.Value) {
+		return object.HashKey{}, false
+	}
+	hashable, ok := obj.(object.Hashable)
+	if !ok {
+		return object.HashKey{}, false
+	}
+	return hashable.HashKey(), true
+}
+
+func evalArrayIndexExpression(bracket token.Token, array *object.Array, index object.Object) object.Object {
+	i, ok := index.(*object.Integer)
+	if !ok {
+		return newError(bracket, "array index must be INTEGER, got %s", index.Type())
+	}
+	idx := i.Value
+	if idx < 0 {
+		idx += int64(len(array.Elements))
+	}
+	if idx < 0 || idx >= int64(len(array.Elements)) {
+		return newError(bracket, "index out of range: %d with length %d", i.Value, len(array.Elements))
+	}
+	return array.Elements[idx]
+}
+
+// evalSliceExpression evaluates left[low:high] to a new array holding
+// the elements from low up to but excluding high. Negative bounds count
+// from the end of the array; omitted bounds default to its start and end.
+func evalSliceExpression(slice ast.SliceExpression, env *object.Environment) object.Object {
+	left := eval(slice.Left, env)
+	if isAbrupt(left) {
+		return left
+	}
+	array, ok := left.(*object.Array)
+	if !ok {
+		return newError(slice.Bracket, "slice operator not supported: %s", left.Type())
+	}
+	length := int64(len(array.Elements))
+	bounds := []int64{0, length}
+	for i, node := range []ast.Expression{slice.Low, slice.High} {
+		if node == nil {
+			continue
+		}
+		bound := eval(node, env)
+		if isAbrupt(bound) {
+			return bound
+		}
+		b, ok := bound.(*object.Integer)
+		if !ok {
+			return newError(slice.Bracket, "slice bound must be INTEGER, got %s", bound.Type())
+		}
+		bounds[i] = b.Value
+		if bounds[i] < 0 {
+			bounds[i] += length
+		}
+	}
+	low, high := bounds[0], bounds[1]
+	if low < 0 || high > length || low > high {
+		return newError(slice.Bracket, "slice bounds out of range: [%d:%d] with length %d", low, high, length)
+	}
+	elements := make([]object.Object, high-low)
+	copy(elements, array.Elements[low:high])
+	return &object.Array{Elements: elements}
+}
+
+func newFunction(name string, params []ast.IdentifierExpression, body ast.Block, env *object.Environment) *object.Function {
+	return &object.Function{
+		Name:       name,
+		Parameters: params,
+		Body:       body,
+		Env:        env,
+	}
+}
+
+func applyFunction(call token.Token, fn object.Object, args []object.Object, caller *object.Environment) object.Object {
+	if builtin, ok := fn.(*object.Builtin); ok {
+		result := builtin.Fn(caller, args...)
+		if err, ok := result.(*object.Error); ok {
+			return newError(call, "%s", err.Message)
+		}
+		return result
+	}
+	function, ok := fn.(*object.Function)
+	if !ok {
+		return newError(call, "not a function: %s", fn.Type())
+	}
+	if len(args) != len(function.Parameters) {
+		return newError(call, "wrong number of arguments: want=%d, got=%d", len(function.Parameters), len(args))
+	}
+	env := object.NewEnclosedEnvironment(function.Env)
+	for i, param := range function.Parameters {
+		env.Set(param.Identifier.Literal, args[i])
+	}
+	switch result := evalStatements(function.Body.Ast, env).(type) {
+	case *object.ReturnValue:
+		return result.Value
+	case *object.YieldValue:
+		return newErrorAt(result.Span, "yield outside of block expression")
+	case *object.Error:
+		return result
+	default:
+		return NULL
+	}
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj := obj.(type) {
+	case *object.Boolean:
+		return obj.Value
+	case *object.Null:
+		return false
+	default:
+		return true
+	}
+}
+
+// isAbrupt reports whether obj ends the evaluation of an expression
+// early, either because it is an error or because it is a return value
+// unwinding to the enclosing function.
+func isAbrupt(obj object.Object) bool {
+	return obj != nil && (obj.Type() == object.ERROR || obj.Type() == object.RETURN)
+}
+
+func nativeBoolToBooleanObject(b bool) *object.Boolean {
+	if b {
+		return TRUE
+	}
+	return FALSE
+}
+
+func newError(t token.Token, format string, a ...any) *object.Error {
+	return newErrorAt(t.Span(), format, a...)
+}
+
+// newErrorAt returns an error at the start of span, for nodes that have
+// no single token to blame.
+func newErrorAt(span token.Span, format string, a ...any) *object.Error {
+	return &object.Error{Message: fmt.Sprintf("%s:%d:%d: %s", span.File, span.Line, span.Column, fmt.Sprintf(format, a...))}
+}
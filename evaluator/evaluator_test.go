@@ -0,0 +1,334 @@
+package evaluator_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tobiashort/utils-go/strings"
+
+	"github.com/tobiashort/monkey/evaluator"
+	"github.com/tobiashort/monkey/lexer"
+	"github.com/tobiashort/monkey/object"
+	"github.com/tobiashort/monkey/parser"
+)
+
+func test(t *testing.T, input string, expected string) {
+	l := lexer.New("", input)
+	tokens, err := l.Analyze()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := parser.New(tokens)
+	ast, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := "<nil>"
+	if result := evaluator.Eval(ast, object.NewEnvironment()); result != nil {
+		actual = result.Inspect()
+	}
+
+	if expected != actual {
+		t.Fatalf(
+			strings.Dedent(`
+				           |Input:    %s
+				           |Expected: %s
+				           |Got:      %s`),
+			input,
+			expected,
+			actual)
+	}
+}
+
+func TestEval(t *testing.T) {
+	test(t, `5;`, "5")
+	test(t, `1 + 2 * 3;`, "7")
+	test(t, `(1 + 2) * 3;`, "9")
+	test(t, `10 / 3;`, "3")
+	test(t, `1.5 * 2.0;`, "3.0")
+	test(t, `"foo" + "bar";`, `"foobar"`)
+	test(t, `1 < 2;`, "true")
+	test(t, `1.5 >= 2.5;`, "false")
+	test(t, `"a" == "a";`, "true")
+	test(t, `1 == "1";`, "false")
+	test(t, `(1 < 2) != (2 < 1);`, "true")
+	test(t, `(-5);`, "-5")
+	test(t, `(!(1 < 2));`, "false")
+}
+
+func TestEval2(t *testing.T) {
+	test(t, `let a = 5; let b = a * 2; b;`, "10")
+	test(t, `let a = 5;`, "<nil>")
+}
+
+func TestEval3(t *testing.T) {
+	input := strings.Dedent(`let x = 10;
+	                        |if (x > 5) {
+	                        |  return "big";
+	                        |} else {
+	                        |  return "small";
+	                        |}`)
+	test(t, input, `"big"`)
+}
+
+func TestEval4(t *testing.T) {
+	input := strings.Dedent(`let x = 3;
+	                        |let y = if (x > 5) { yield "big"; } else { yield "small"; };
+	                        |y;`)
+	test(t, input, `"small"`)
+}
+
+func TestEval5(t *testing.T) {
+	input := strings.Dedent(`fn fib(n) {
+	                        |  if (n < 2) {
+	                        |    return n;
+	                        |  }
+	                        |  return fib(n - 1) + fib(n - 2);
+	                        |}
+	                        |fib(10);`)
+	test(t, input, "55")
+}
+
+func TestEval6(t *testing.T) {
+	input := strings.Dedent(`let add = fn(a, b) { return a + b; };
+	                        |add(20, 22);`)
+	test(t, input, "42")
+}
+
+func TestEval7(t *testing.T) {
+	test(t, `1 + "a";`, "ERROR: :1:3: type mismatch: INTEGER + STRING")
+	test(t, `1 / 0;`, "ERROR: :1:3: division by zero")
+	test(t, `(-"a");`, "ERROR: :1:2: unknown operator: -STRING")
+	test(t, `foo;`, "ERROR: :1:1: identifier not found: foo")
+	test(t, `let f = fn(a) { return a; }; f(1, 2);`, "ERROR: :1:31: wrong number of arguments: want=1, got=2")
+	test(t, `let f = 1; f(1);`, "ERROR: :1:13: not a function: INTEGER")
+}
+
+func TestEval8(t *testing.T) {
+	input := strings.Dedent(`let x = 1;
+	                        |{
+	                        |  let x = 2;
+	                        |  let y = 3;
+	                        |}
+	                        |x;`)
+	test(t, input, "1")
+	test(t, `{ let y = 3; } y;`, "ERROR: :1:16: identifier not found: y")
+}
+
+func TestEval9(t *testing.T) {
+	input := strings.Dedent(`fn makeAdder(x) {
+	                        |  return fn(y) { return x + y; };
+	                        |}
+	                        |let addTwo = makeAdder(2);
+	                        |let x = 40;
+	                        |addTwo(x);`)
+	test(t, input, "42")
+}
+
+func TestEval10(t *testing.T) {
+	input := strings.Dedent(`let result = isEven(10);
+	                        |fn isEven(n) {
+	                        |  if (n == 0) { return 1 == 1; }
+	                        |  return isOdd(n - 1);
+	                        |}
+	                        |fn isOdd(n) {
+	                        |  if (n == 0) { return 1 == 0; }
+	                        |  return isEven(n - 1);
+	                        |}
+	                        |result;`)
+	test(t, input, "true")
+}
+
+func TestEval11(t *testing.T) {
+	input := strings.Dedent(`let x = {
+	                        |  let a = 20;
+	                        |  if (a > 10) {
+	                        |    yield a * 2;
+	                        |  }
+	                        |  yield a;
+	                        |};
+	                        |x + 2;`)
+	test(t, input, "42")
+}
+
+func TestEval12(t *testing.T) {
+	input := strings.Dedent(`fn f(n) {
+	                        |  let x = if (n > 0) { return "positive"; } else { yield n; };
+	                        |  return x;
+	                        |}
+	                        |f(1) + f(0);`)
+	test(t, input, "ERROR: :5:6: type mismatch: STRING + INTEGER")
+}
+
+func TestEval13(t *testing.T) {
+	test(t, `let x = if (1 < 2) { 1; } else { yield 2; };`, "ERROR: :1:20: if expression branch did not yield a value")
+	test(t, `let x = { let y = 1; };`, "ERROR: :1:9: block expression did not yield a value")
+	test(t, `yield 1;`, "ERROR: :1:1: yield outside of block expression")
+	test(t, `{ yield 1; }`, "ERROR: :1:3: yield outside of block expression")
+	test(t, `fn f() { yield 1; } f();`, "ERROR: :1:10: yield outside of block expression")
+}
+
+func TestEval14(t *testing.T) {
+	test(t, `1 + 2.5;`, "3.5")
+	test(t, `2.5 * 2;`, "5.0")
+	test(t, `7 / 2;`, "3")
+	test(t, `7 / 2.0;`, "3.5")
+	test(t, `1 / 0.0;`, "+Inf")
+	test(t, `1 == 1.0;`, "true")
+	test(t, `2 > 1.5;`, "true")
+	test(t, `let x = 0.0 / 0.0; x == x;`, "false")
+}
+
+func TestEval15(t *testing.T) {
+	test(t, `9223372036854775807 + 1;`, "ERROR: :1:21: integer overflow: 9223372036854775807 + 1")
+	test(t, `let min = 0 - 9223372036854775807 - 1; min - 1;`, "ERROR: :1:44: integer overflow: -9223372036854775808 - 1")
+	test(t, `4611686018427387904 * 2;`, "ERROR: :1:21: integer overflow: 4611686018427387904 * 2")
+	test(t, `let min = 0 - 9223372036854775807 - 1; min / (0 - 1);`, "ERROR: :1:44: integer overflow: -9223372036854775808 / -1")
+	test(t, `let min = 0 - 9223372036854775807 - 1; (-min);`, "ERROR: :1:41: integer overflow: -(-9223372036854775808)")
+	test(t, `9223372036854775807 * 1;`, "9223372036854775807")
+}
+
+func TestEval16(t *testing.T) {
+	test(t, `int(3.9);`, "3")
+	test(t, `int(0.0 - 3.9);`, "-3")
+	test(t, `int("42");`, "42")
+	test(t, `float(2);`, "2.0")
+	test(t, `float("1.5");`, "1.5")
+	test(t, `int(1.0 / 0.0);`, "ERROR: :1:4: cannot convert +Inf to INTEGER: out of range")
+	test(t, `int("abc");`, `ERROR: :1:4: cannot convert "abc" to INTEGER`)
+	test(t, `float(1, 2);`, "ERROR: :1:6: wrong number of arguments to `float`: want=1, got=2")
+}
+
+func TestEval17(t *testing.T) {
+	test(t, `(1 < 2) && (2 < 3);`, "true")
+	test(t, `(1 > 2) || "yes";`, "true")
+	test(t, `(1 > 2) && undefined;`, "false")
+	test(t, `(1 < 2) || undefined;`, "true")
+	test(t, `(1 < 2) && undefined;`, "ERROR: :1:12: identifier not found: undefined")
+}
+
+func TestEval18(t *testing.T) {
+	test(t, `12 & 10;`, "8")
+	test(t, `12 | 10;`, "14")
+	test(t, `(1 < 2) & (2 > 3);`, "false")
+	test(t, `(1 < 2) | (2 > 3);`, "true")
+	test(t, `1.5 & 1;`, "ERROR: :1:5: unknown operator: FLOAT & INTEGER")
+	test(t, `"a" | "b";`, "ERROR: :1:5: unknown operator: STRING | STRING")
+	test(t, `(1 < 2) & 1;`, "ERROR: :1:9: type mismatch: BOOLEAN & INTEGER")
+}
+
+func TestEval19(t *testing.T) {
+	test(t, `true;`, "true")
+	test(t, `let x = !false; x;`, "true")
+	test(t, `null;`, "null")
+	test(t, `true && null;`, "false")
+	test(t, `null == null;`, "true")
+}
+
+func TestEval20(t *testing.T) {
+	test(t, `[1, 2 * 2, "three"];`, `[1, 4, "three"]`)
+	test(t, `[];`, "[]")
+	test(t, `let a = [1, 2, 3]; a[0] + a[2];`, "4")
+	test(t, `let a = [1, 2, 3]; a[-1];`, "3")
+	test(t, `let a = [1, 2, 3]; a[3];`, "ERROR: :1:21: index out of range: 3 with length 3")
+	test(t, `let a = [1, 2, 3]; a["x"];`, "ERROR: :1:21: array index must be INTEGER, got STRING")
+	test(t, `[[1, 2], [3]] == [[1, 2], [3]];`, "true")
+	test(t, `len([1, [2, 3]]);`, "2")
+}
+
+func TestEval21(t *testing.T) {
+	test(t, `let a = [1, 2, 3, 4]; a[1:3];`, "[2, 3]")
+	test(t, `let a = [1, 2, 3, 4]; a[:2];`, "[1, 2]")
+	test(t, `let a = [1, 2, 3, 4]; a[2:];`, "[3, 4]")
+	test(t, `let a = [1, 2, 3, 4]; a[-3:-1];`, "[2, 3]")
+	test(t, `let a = [1, 2, 3, 4]; a[:];`, "[1, 2, 3, 4]")
+	test(t, `let a = [1, 2, 3, 4]; a[3:1];`, "ERROR: :1:24: slice bounds out of range: [3:1] with length 4")
+}
+
+func TestEval22(t *testing.T) {
+	test(t, `let h = {"a": 1, 2: "b", true: [3]}; h;`, `{"a": 1, 2: "b", true: [3]}`)
+	test(t, `let h = {"a": 1, 2: "b", true: [3]}; h["a"];`, "1")
+	test(t, `let h = {"a": 1, 2: "b", true: [3]}; h[1 + 1];`, `"b"`)
+	test(t, `let h = {"a": 1, 2: "b", true: [3]}; h[1 < 2][0];`, "3")
+	test(t, `let h = {"a": 1}; h["b"];`, "null")
+	test(t, `let h = {}; len(h);`, "0")
+	test(t, `({"a": 1, "b": 2} == {"b": 2, "a": 1});`, "true")
+	test(t, `let h = {null: 1};`, "ERROR: :1:9: unusable as hash key: NULL")
+	test(t, `let h = {"a": 1}; h[[1]];`, "ERROR: :1:20: unusable as hash key: ARRAY")
+}
+
+func TestEval23(t *testing.T) {
+	test(t, `let x = { let a = 1; yield a; }; x;`, "1")
+	test(t, `let x = { a: 1 }; x;`, "ERROR: :1:11: identifier not found: a")
+}
+
+func TestEval24(t *testing.T) {
+	test(t, `fn(x) { return x * 2; }(21);`, "42")
+	test(t, `fn makeAdder(x) { return fn(y) { return x + y; }; } makeAdder(40)(2);`, "42")
+	test(t, `let f = fn(x) { return x; }; (f)(42);`, "42")
+	test(t, `let fs = [fn(x) { return x + 1; }]; fs[0](41);`, "42")
+	test(t, `fn curry(f) { return fn(a) { return fn(b) { return f(a, b); }; }; } curry(fn(a, b) { return a - b; })(50)(8);`, "42")
+}
+
+func TestEval25(t *testing.T) {
+	input := strings.Dedent(`// answer
+	                        |let x = 40; /* plus
+	                        |two */ x + 2;`)
+	test(t, input, "42")
+}
+
+func TestEval26(t *testing.T) {
+	test(t, `len("a\tb\u{E9}");`, "4")
+	test(t, `"say \"hi\"\n";`, `"say \"hi\"\n"`)
+}
+
+func TestEval27(t *testing.T) {
+	test(t, `0xFF + 0o17 + 0b1010;`, "280")
+	test(t, `1_000_000;`, "1000000")
+	test(t, `1e3;`, "1000.0")
+	test(t, `1.5e-3;`, "0.0015")
+	test(t, `0x7FFF_FFFF_FFFF_FFFF;`, "9223372036854775807")
+}
+
+func TestEval28(t *testing.T) {
+	test(t, `-1 + 2;`, "1")
+	test(t, `let a = 3; -a * 2 + 10;`, "4")
+	test(t, `!true == false;`, "true")
+	test(t, `10 - 4 - 3;`, "3")
+}
+
+func TestEval29(t *testing.T) {
+	test(t, `[1] == [1.0];`, "true")
+	test(t, `[1, [2]] != [1.0, [2.5]];`, "true")
+	test(t, `({1: "a"})[1.0];`, `"a"`)
+	test(t, `({2.0: "b"})[2];`, `"b"`)
+	test(t, `({1.5: "c"})[1.5];`, `"c"`)
+	test(t, `({1: "a"} == {1.0: "a"});`, "true")
+	test(t, `let n = 0.0 / 0.0; n == n;`, "false")
+	test(t, `let n = 0.0 / 0.0; ({n: 1});`, "ERROR: :1:21: unusable as hash key: FLOAT")
+	test(t, `let n = 0.0 / 0.0; ({1: "a"})[n];`, "ERROR: :1:30: unusable as hash key: FLOAT")
+}
+
+func TestEval30(t *testing.T) {
+	input := `puts("a", 1); fn f(x) { puts([x]); } f("b");`
+	tokens, err := lexer.New("", input).Analyze()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast, err := parser.New(tokens).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	env := object.NewEnvironment()
+	env.SetOutput(&out)
+	evaluator.Eval(ast, env)
+
+	expected := "a\n1\n[\"b\"]\n"
+	if out.String() != expected {
+		t.Fatalf("Expected: %q, Got: %q", expected, out.String())
+	}
+}
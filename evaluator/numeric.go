@@ -0,0 +1,118 @@
+package evaluator
+
+import (
+	"math"
+
+	"github.com/tobiashort/monkey/object"
+	"github.com/tobiashort/monkey/token"
+)
+
+func isNumber(obj object.Object) bool {
+	switch obj.Type() {
+	case object.INTEGER, object.FLOAT:
+		return true
+	default:
+		return false
+	}
+}
+
+// evalNumberBinaryExpression applies operator to two numbers. If both
+// are integers the result is an integer, and overflow is an error.
+// Otherwise the integer operand is promoted to a float and the result
+// follows IEEE 754, so float division by zero yields an infinity or NaN.
+func evalNumberBinaryExpression(operator token.Token, left, right object.Object) object.Object {
+	l, lok := left.(*object.Integer)
+	r, rok := right.(*object.Integer)
+	if lok && rok {
+		return evalIntegerBinaryExpression(operator, l.Value, r.Value)
+	}
+	if operator.Type == token.BAND || operator.Type == token.BOR {
+		return newError(operator, "unknown operator: %s %s %s", left.Type(), operator.Literal, right.Type())
+	}
+	return evalFloatBinaryExpression(operator, toFloat(left), toFloat(right))
+}
+
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.Float:
+		return obj.Value
+	default:
+		panic("not a number: " + obj.Type())
+	}
+}
+
+func evalIntegerBinaryExpression(operator token.Token, left, right int64) object.Object {
+	switch operator.Type {
+	case token.PLUS:
+		sum := left + right
+		if (right > 0 && sum < left) || (right < 0 && sum > left) {
+			return newError(operator, "integer overflow: %d + %d", left, right)
+		}
+		return &object.Integer{Value: sum}
+	case token.MINUS:
+		diff := left - right
+		if (right > 0 && diff > left) || (right < 0 && diff < left) {
+			return newError(operator, "integer overflow: %d - %d", left, right)
+		}
+		return &object.Integer{Value: diff}
+	case token.ASTERISK:
+		product := left * right
+		if left != 0 && (product/left != right || (left == -1 && right == math.MinInt64)) {
+			return newError(operator, "integer overflow: %d * %d", left, right)
+		}
+		return &object.Integer{Value: product}
+	case token.SLASH:
+		if right == 0 {
+			return newError(operator, "division by zero")
+		}
+		if left == math.MinInt64 && right == -1 {
+			return newError(operator, "integer overflow: %d / %d", left, right)
+		}
+		return &object.Integer{Value: left / right}
+	case token.BAND:
+		return &object.Integer{Value: left & right}
+	case token.BOR:
+		return &object.Integer{Value: left | right}
+	case token.LT:
+		return nativeBoolToBooleanObject(left < right)
+	case token.GT:
+		return nativeBoolToBooleanObject(left > right)
+	case token.LEQT:
+		return nativeBoolToBooleanObject(left <= right)
+	case token.GEQT:
+		return nativeBoolToBooleanObject(left >= right)
+	case token.EQUAL:
+		return nativeBoolToBooleanObject(left == right)
+	case token.NOT_EQUAL:
+		return nativeBoolToBooleanObject(left != right)
+	}
+	return newError(operator, "unknown operator: %s %s %s", object.INTEGER, operator.Literal, object.INTEGER)
+}
+
+func evalFloatBinaryExpression(operator token.Token, left, right float64) object.Object {
+	switch operator.Type {
+	case token.PLUS:
+		return &object.Float{Value: left + right}
+	case token.MINUS:
+		return &object.Float{Value: left - right}
+	case token.ASTERISK:
+		return &object.Float{Value: left * right}
+	case token.SLASH:
+		return &object.Float{Value: left / right}
+	case token.LT:
+		return nativeBoolToBooleanObject(left < right)
+	case token.GT:
+		return nativeBoolToBooleanObject(left > right)
+	case token.LEQT:
+		return nativeBoolToBooleanObject(left <= right)
+	case token.GEQT:
+		return nativeBoolToBooleanObject(left >= right)
+	case token.EQUAL:
+		return nativeBoolToBooleanObject(left == right)
+	case token.NOT_EQUAL:
+		return nativeBoolToBooleanObject(left != right)
+	}
+	return newError(operator, "unknown operator: %s %s %s", object.FLOAT, operator.Literal, object.FLOAT)
+}
@@ -1,490 +1,647 @@
 package lexer
 
 import (
+	"io"
+	"iter"
 	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 
+	"github.com/tobiashort/monkey/diag"
 	"github.com/tobiashort/monkey/token"
-	"github.com/tobiashort/utils-go/errors"
-	"github.com/tobiashort/utils-go/option"
 )
 
+// eof is returned by rune and peek past the end of the input.
+const eof = -1
+
+var keywords = map[string]token.TokenType{
+	"let":    token.LET,
+	"fn":     token.FUNCTION,
+	"if":     token.IF,
+	"else":   token.ELSE,
+	"true":   token.TRUE,
+	"false":  token.FALSE,
+	"null":   token.NULL,
+	"return": token.RETURN,
+	"yield":  token.YIELD,
+}
+
+var delimiters = map[rune]token.TokenType{
+	'+': token.PLUS,
+	'-': token.MINUS,
+	'*': token.ASTERISK,
+	',': token.COMMA,
+	';': token.SEMICOLON,
+	':': token.COLON,
+	'(': token.LPAREN,
+	')': token.RPAREN,
+	'{': token.LBRACE,
+	'}': token.RBRACE,
+	'[': token.LBRACKET,
+	']': token.RBRACKET,
+}
+
+// chunkSize is the minimum number of bytes read from a reader at once.
+const chunkSize = 4096
+
+// keptLines is the number of recent lines a lexer reading from an
+// io.Reader keeps, to show diagnostics in.
+const keptLines = 64
+
+// Lexer scans its input once from start to end. offset is the byte
+// offset of the current rune, while line and column count runes, so
+// that positions match what editors show for UTF-8 sources.
+//
+// When reading from an io.Reader, input only holds the bytes from the
+// start of the current line onwards, and is refilled as needed. base is
+// then the number of bytes dropped before it. The last keptLines lines
+// before the current one are kept in recent.
 type Lexer struct {
-	file     string
-	input    string
-	position int
-	line     int
-	column   int
+	file      string
+	input     string
+	reader    io.Reader
+	streaming bool
+	base      int
+	offset    int
+	lineStart int
+	line      int
+	column    int
+	recent    [keptLines]sourceLine
+	comments  bool
+	err       error
+}
+
+type sourceLine struct {
+	n    int
+	text string
 }
 
 func New(file string, input string) *Lexer {
 	return &Lexer{
-		file:     file,
-		input:    input,
-		position: 0,
-		line:     1,
-		column:   1,
+		file:   file,
+		input:  input,
+		offset: 0,
+		line:   1,
+		column: 1,
+	}
+}
+
+// NewReader returns a lexer that reads its input from r as tokens are
+// requested, so that large inputs need not be held in memory at once.
+func NewReader(file string, r io.Reader) *Lexer {
+	return &Lexer{
+		file:      file,
+		reader:    r,
+		streaming: true,
+		offset:    0,
+		line:      1,
+		column:    1,
 	}
 }
 
+// KeepComments makes the lexer emit comments as COMMENT tokens instead
+// of skipping them, for tools that need to preserve them.
+func (l *Lexer) KeepComments() *Lexer {
+	l.comments = true
+	return l
+}
+
 func (l *Lexer) Analyze() ([]token.Token, error) {
-	var tokens []token.Token
-	for {
-		t := l.nextToken()
+	// a rough guess at the token count saves most of the reallocations
+	tokens := make([]token.Token, 0, len(l.input)/4+1)
+	for t, err := range l.All() {
 		tokens = append(tokens, t)
-		if t.Type == token.EOF {
-			return tokens, nil
+		if err != nil {
+			return tokens, err
 		}
-		if t.Type == token.ILLEGAL {
-			return tokens, errors.WithCtxf("%s:%d:%d illegal token %q", t.File, t.Line, t.Column, t.Literal)
+	}
+	return tokens, nil
+}
+
+// Next scans and returns the next token. Once the input is exhausted it
+// keeps returning EOF. An ILLEGAL token is returned together with a
+// diag.Diagnostic describing it, which is kept for all later calls.
+func (l *Lexer) Next() (token.Token, error) {
+	t := l.nextToken()
+	if l.err != nil {
+		return t, l.err
+	}
+	if t.Type == token.ILLEGAL {
+		l.fail(diag.Errorf(diag.ILLEGAL_CHARACTER, t.Span(), "illegal character %q", t.Literal))
+		return t, l.err
+	}
+	return t, nil
+}
+
+// All returns an iterator over the remaining tokens, up to and including
+// EOF or the first ILLEGAL token.
+func (l *Lexer) All() iter.Seq2[token.Token, error] {
+	return func(yield func(token.Token, error) bool) {
+		for {
+			t, err := l.Next()
+			if !yield(t, err) || err != nil || t.Type == token.EOF {
+				return
+			}
 		}
 	}
 }
 
 func (l *Lexer) nextToken() token.Token {
-	var tok = option.None[token.Token]()
+	for {
+		if t := l.scan(); t.Type != token.COMMENT || l.comments {
+			return t
+		}
+	}
+}
 
-	if r := l.rune(); r == '\n' {
-		l.position++
-		l.line++
-		l.column = 1
-		return l.nextToken()
+// scan scans the token at the current position, after skipping any
+// whitespace.
+func (l *Lexer) scan() token.Token {
+	for unicode.IsSpace(l.rune()) {
+		l.advance()
+	}
+	if l.reader != nil {
+		// earlier lines are done with, only keep what is left
+		l.base += l.lineStart
+		l.input = l.input[l.lineStart:]
+		l.offset -= l.lineStart
+		l.lineStart = 0
 	}
 
-	if r := l.rune(); unicode.IsSpace(r) {
-		l.position++
-		l.column++
-		return l.nextToken()
+	tok := token.Token{
+		File:   l.file,
+		Line:   l.line,
+		Column: l.column,
 	}
+	start := l.offset
 
 	switch r := l.rune(); r {
-	case '!':
-		tok = option.Some(token.Token{
-			Type:    token.BANG,
-			Literal: string(r),
-			File:    l.file,
-			Line:    l.line,
-			Column:  l.column,
-		})
-		l.position++
-		l.column++
-		if nr := l.rune(); nr == '=' {
-			tok = option.Some(token.Token{
-				Type:    token.NOT_EQUAL,
-				Literal: string(r) + string(nr),
-				File:    l.file,
-				Line:    l.line,
-				Column:  l.column - 1,
-			})
-			l.position++
-			l.column++
+	case eof:
+		tok.Type = token.EOF
+	case '"':
+		tok = l.string()
+	case '/':
+		if nr := l.peek(); nr == '/' || nr == '*' {
+			tok = l.comment()
+		} else {
+			tok.Type = token.SLASH
+			l.advance()
 		}
+	case '!':
+		tok.Type = l.operator(token.BANG, '=', token.NOT_EQUAL)
 	case '=':
-		tok = option.Some(token.Token{
-			Type:    token.ASSIGN,
-			Literal: string(r),
-			File:    l.file,
-			Line:    l.line,
-			Column:  l.column,
-		})
-		l.position++
-		l.column++
-		if nr := l.rune(); nr == '=' {
-			tok = option.Some(token.Token{
-				Type:    token.EQUAL,
-				Literal: string(r) + string(nr),
-				File:    l.file,
-				Line:    l.line,
-				Column:  l.column - 1,
-			})
-			l.position++
-			l.column++
-		}
+		tok.Type = l.operator(token.ASSIGN, '=', token.EQUAL)
 	case '&':
-		tok = option.Some(token.Token{
-			Type:    token.BAND,
-			Literal: string(r),
-			File:    l.file,
-			Line:    l.line,
-			Column:  l.column,
-		})
-		l.position++
-		l.column++
-		if nr := l.rune(); nr == '&' {
-			tok = option.Some(token.Token{
-				Type:    token.LAND,
-				Literal: string(r) + string(nr),
-				File:    l.file,
-				Line:    l.line,
-				Column:  l.column - 1,
-			})
-			l.position++
-			l.column++
-		}
+		tok.Type = l.operator(token.BAND, '&', token.LAND)
 	case '|':
-		tok = option.Some(token.Token{
-			Type:    token.BOR,
-			Literal: string(r),
-			File:    l.file,
-			Line:    l.line,
-			Column:  l.column,
-		})
-		l.position++
-		l.column++
-		if nr := l.rune(); nr == '|' {
-			tok = option.Some(token.Token{
-				Type:    token.LOR,
-				Literal: string(r) + string(nr),
-				File:    l.file,
-				Line:    l.line,
-				Column:  l.column - 1,
-			})
-			l.position++
-			l.column++
-		}
-	case '+':
-		tok = option.Some(token.Token{
-			Type:    token.PLUS,
-			Literal: string(r),
-			File:    l.file,
-			Line:    l.line,
-			Column:  l.column,
-		})
-		l.position++
-		l.column++
-	case '-':
-		tok = option.Some(token.Token{
-			Type:    token.MINUS,
-			Literal: string(r),
-			File:    l.file,
-			Line:    l.line,
-			Column:  l.column,
-		})
-		l.position++
-		l.column++
-	case '/':
-		tok = option.Some(token.Token{
-			Type:    token.SLASH,
-			Literal: string(r),
-			File:    l.file,
-			Line:    l.line,
-			Column:  l.column,
-		})
-		l.position++
-		l.column++
-	case '*':
-		tok = option.Some(token.Token{
-			Type:    token.ASTERISK,
-			Literal: string(r),
-			File:    l.file,
-			Line:    l.line,
-			Column:  l.column,
-		})
-		l.position++
-		l.column++
+		tok.Type = l.operator(token.BOR, '|', token.LOR)
 	case '<':
-		tok = option.Some(token.Token{
-			Type:    token.LT,
-			Literal: string(r),
-			File:    l.file,
-			Line:    l.line,
-			Column:  l.column,
-		})
-		l.position++
-		l.column++
-		if nr := l.rune(); nr == '=' {
-			tok = option.Some(token.Token{
-				Type:    token.LEQT,
-				Literal: string(r) + string(nr),
-				File:    l.file,
-				Line:    l.line,
-				Column:  l.column - 1,
-			})
-			l.position++
-			l.column++
-		}
+		tok.Type = l.operator(token.LT, '=', token.LEQT)
 	case '>':
-		tok = option.Some(token.Token{
-			Type:    token.GT,
-			Literal: string(r),
-			File:    l.file,
-			Line:    l.line,
-			Column:  l.column,
-		})
-		l.position++
-		l.column++
-		if nr := l.rune(); nr == '=' {
-			tok = option.Some(token.Token{
-				Type:    token.GEQT,
-				Literal: string(r) + string(nr),
-				File:    l.file,
-				Line:    l.line,
-				Column:  l.column - 1,
-			})
-			l.position++
-			l.column++
+		tok.Type = l.operator(token.GT, '=', token.GEQT)
+	default:
+		if t, ok := delimiters[r]; ok {
+			tok.Type = t
+			l.advance()
+		} else if unicode.IsLetter(r) {
+			l.field()
+			tok.Type = token.IDENT
+			if t, ok := keywords[l.input[start:l.offset]]; ok {
+				tok.Type = t
+			}
+		} else if '0' <= r && r <= '9' {
+			tok = l.number()
+		} else {
+			tok.Type = token.ILLEGAL
+			l.advance()
 		}
-	case ',':
-		tok = option.Some(token.Token{
-			Type:    token.COMMA,
-			Literal: string(r),
-			File:    l.file,
-			Line:    l.line,
-			Column:  l.column,
-		})
-		l.position++
-		l.column++
-	case ';':
-		tok = option.Some(token.Token{
-			Type:    token.SEMICOLON,
-			Literal: string(r),
-			File:    l.file,
-			Line:    l.line,
-			Column:  l.column,
-		})
-		l.position++
-		l.column++
-	case '(':
-		tok = option.Some(token.Token{
-			Type:    token.LPAREN,
-			Literal: string(r),
-			File:    l.file,
-			Line:    l.line,
-			Column:  l.column,
-		})
-		l.position++
-		l.column++
-	case ')':
-		tok = option.Some(token.Token{
-			Type:    token.RPAREN,
-			Literal: string(r),
-			File:    l.file,
-			Line:    l.line,
-			Column:  l.column,
-		})
-		l.position++
-		l.column++
-	case '{':
-		tok = option.Some(token.Token{
-			Type:    token.LBRACE,
-			Literal: string(r),
-			File:    l.file,
-			Line:    l.line,
-			Column:  l.column,
-		})
-		l.position++
-		l.column++
-	case '}':
-		tok = option.Some(token.Token{
-			Type:    token.RBRACE,
-			Literal: string(r),
-			File:    l.file,
-			Line:    l.line,
-			Column:  l.column,
-		})
-		l.position++
-		l.column++
-	case '"':
-		literal := "\""
-		escaped := false
-		for {
-			l.position++
-			if l.rune() == '\\' {
-				escaped = true
-				continue
+	}
+
+	tok.Literal = l.input[start:l.offset]
+	tok.Offset = l.base + start
+	tok.End = l.base + l.offset
+	return tok
+}
+
+// operator scans a one rune operator of type single, or of type double
+// if it is followed by next.
+func (l *Lexer) operator(single token.TokenType, next rune, double token.TokenType) token.TokenType {
+	l.advance()
+	if l.rune() == next {
+		l.advance()
+		return double
+	}
+	return single
+}
+
+// field moves past the identifier or number starting at the current
+// position.
+func (l *Lexer) field() {
+	for {
+		r := l.rune()
+		if r == eof {
+			return
+		}
+		if unicode.IsSpace(r) || unicode.IsSymbol(r) || unicode.IsPunct(r) {
+			if r != '_' && r != '.' {
+				return
 			}
-			if l.rune() == '"' && !escaped {
-				break
+		}
+		l.advance()
+	}
+}
+
+// number scans a number literal starting at the current position: a
+// decimal integer or float with an optional fraction and exponent, or an
+// integer with a 0x, 0o or 0b base prefix. Underscores may separate
+// digits. A malformed literal yields an ILLEGAL token, and an error
+// pointing at the first offending character.
+func (l *Lexer) number() token.Token {
+	tok := token.Token{
+		Type:   token.INT,
+		File:   l.file,
+		Line:   l.line,
+		Column: l.column,
+	}
+	start := l.offset
+	fail := func(span token.Span, format string, args ...any) token.Token {
+		tok.Type = token.ILLEGAL
+		l.fail(diag.Errorf(diag.INVALID_NUMBER, span, format, args...))
+		for r := l.rune(); r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r); r = l.rune() {
+			l.advance()
+		}
+		return tok
+	}
+
+	base, name := 10, "decimal"
+	if l.rune() == '0' {
+		switch l.peek() {
+		case 'x', 'X':
+			base, name = 16, "hexadecimal"
+		case 'o', 'O':
+			base, name = 8, "octal"
+		case 'b', 'B':
+			base, name = 2, "binary"
+		}
+	}
+
+	if base != 10 {
+		l.advance()
+		l.advance()
+		if n, ok := l.digits(base, true); !ok {
+			return fail(l.here(), "'_' must separate successive digits")
+		} else if n == 0 {
+			return fail(l.here(), "%s literal has no digits", name)
+		}
+	} else {
+		if _, ok := l.digits(10, false); !ok {
+			return fail(l.here(), "'_' must separate successive digits")
+		}
+		if l.rune() == '.' {
+			tok.Type = token.FLOAT
+			l.advance()
+			if n, ok := l.digits(10, false); !ok {
+				return fail(l.here(), "'_' must separate successive digits")
+			} else if n == 0 {
+				return fail(l.here(), "expected digit after '.'")
 			}
-			if escaped {
-				escaped = false
+		}
+		if r := l.rune(); r == 'e' || r == 'E' {
+			tok.Type = token.FLOAT
+			l.advance()
+			if r := l.rune(); r == '+' || r == '-' {
+				l.advance()
+			}
+			if n, ok := l.digits(10, false); !ok {
+				return fail(l.here(), "'_' must separate successive digits")
+			} else if n == 0 {
+				return fail(l.here(), "exponent has no digits")
 			}
-			literal = literal + string(l.rune())
 		}
-		literal += "\""
-		tok = option.Some(token.Token{
-			Type:    token.STRING,
-			Literal: literal,
-			File:    l.file,
-			Line:    l.line,
-			Column:  l.column,
-		})
-		l.position++
-		l.column += len(literal)
-	case 0:
-		tok = option.Some(token.Token{
-			Type:    token.EOF,
-			Literal: "",
-			File:    l.file,
-			Line:    l.line,
-			Column:  l.column,
-		})
-		l.position++
-		l.column++
 	}
 
-	if !tok.None {
-		return tok.Val
-	}
-
-	if r := l.rune(); unicode.IsLetter(r) {
-		switch f := l.field(); f {
-		case "let":
-			tok = option.Some(token.Token{
-				Type:    token.LET,
-				Literal: f,
-				File:    l.file,
-				Line:    l.line,
-				Column:  l.column,
-			})
-			l.position += len(f)
-			l.column += len(f)
-		case "fn":
-			tok = option.Some(token.Token{
-				Type:    token.FUNCTION,
-				Literal: f,
-				File:    l.file,
-				Line:    l.line,
-				Column:  l.column,
-			})
-			l.position += len(f)
-			l.column += len(f)
-		case "if":
-			tok = option.Some(token.Token{
-				Type:    token.IF,
-				Literal: f,
-				File:    l.file,
-				Line:    l.line,
-				Column:  l.column,
-			})
-			l.position += len(f)
-			l.column += len(f)
-		case "else":
-			tok = option.Some(token.Token{
-				Type:    token.ELSE,
-				Literal: f,
-				File:    l.file,
-				Line:    l.line,
-				Column:  l.column,
-			})
-			l.position += len(f)
-			l.column += len(f)
-		case "false":
-			tok = option.Some(token.Token{
-				Type:    token.FALSE,
-				Literal: f,
-				File:    l.file,
-				Line:    l.line,
-				Column:  l.column,
-			})
-			l.position += len(f)
-			l.column += len(f)
-		case "true":
-			tok = option.Some(token.Token{
-				Type:    token.TRUE,
-				Literal: f,
-				File:    l.file,
-				Line:    l.line,
-				Column:  l.column,
-			})
-			l.position += len(f)
-			l.column += len(f)
-		case "return":
-			tok = option.Some(token.Token{
-				Type:    token.RETURN,
-				Literal: f,
-				File:    l.file,
-				Line:    l.line,
-				Column:  l.column,
-			})
-			l.position += len(f)
-			l.column += len(f)
-		case "yield":
-			tok = option.Some(token.Token{
-				Type:    token.YIELD,
-				Literal: f,
-				File:    l.file,
-				Line:    l.line,
-				Column:  l.column,
-			})
-			l.position += len(f)
-			l.column += len(f)
-		default:
-			if f != "" {
-				tok = option.Some(token.Token{
-					Type:    token.IDENT,
-					Literal: f,
-					File:    l.file,
-					Line:    l.line,
-					Column:  l.column,
-				})
-				l.position += len(f)
-				l.column += len(f)
+	if r := l.rune(); unicode.IsLetter(r) || unicode.IsDigit(r) {
+		return fail(l.here(), "invalid digit %q in %s literal", r, name)
+	} else if r == '_' || r == '.' {
+		return fail(l.here(), "unexpected %q in %s literal", r, name)
+	}
+
+	tok.Literal = l.input[start:l.offset]
+	literal := token.Span{
+		File:   tok.File,
+		Line:   tok.Line,
+		Column: tok.Column,
+		Offset: l.base + start,
+		End:    l.base + l.offset,
+	}
+	if tok.Type == token.INT {
+		if base == 10 && len(tok.Literal) > 1 && tok.Literal[0] == '0' {
+			return fail(literal, "leading zeros are not allowed in decimal literals")
+		}
+		if _, err := strconv.ParseInt(tok.Literal, 0, 64); err != nil {
+			return fail(literal, "integer literal out of range")
+		}
+	} else if _, err := strconv.ParseFloat(tok.Literal, 64); err != nil {
+		return fail(literal, "float literal out of range")
+	}
+	return tok
+}
+
+// digits moves past a run of digits in the given base, which may be
+// separated by single underscores, and returns how many there were. An
+// underscore may also lead the run right after a base prefix. It
+// reports false if an underscore is misplaced, leaving the position at
+// that underscore.
+func (l *Lexer) digits(base int, prefixed bool) (int, bool) {
+	n := 0
+	for {
+		r := l.rune()
+		if r == '_' {
+			if (n == 0 && !prefixed) || digitValue(l.peek()) >= base {
+				return n, false
 			}
+			l.advance()
+			continue
+		}
+		if digitValue(r) >= base {
+			return n, true
 		}
+		n++
+		l.advance()
+	}
+}
+
+// digitValue returns the value of r as a digit, or 16 if r is none.
+func digitValue(r rune) int {
+	switch {
+	case '0' <= r && r <= '9':
+		return int(r - '0')
+	case 'a' <= r && r <= 'f':
+		return int(r - 'a' + 10)
+	case 'A' <= r && r <= 'F':
+		return int(r - 'A' + 10)
+	default:
+		return 16
 	}
+}
 
-	if !tok.None {
-		return tok.Val
-	}
-
-	if r := l.rune(); unicode.IsDigit(r) {
-		f := l.field()
-		if _, err := strconv.ParseInt(f, 10, 64); err == nil {
-			tok = option.Some(token.Token{
-				Type:    token.INT,
-				Literal: f,
-				File:    l.file,
-				Line:    l.line,
-				Column:  l.column,
-			})
-			l.position += len(f)
-			l.column += len(f)
-		} else if _, err := strconv.ParseFloat(f, 64); err == nil {
-			tok = option.Some(token.Token{
-				Type:    token.FLOAT,
-				Literal: f,
-				File:    l.file,
-				Line:    l.line,
-				Column:  l.column,
-			})
-			l.position += len(f)
-			l.column += len(f)
+// string scans a string literal starting at the current position. The
+// token's Literal is the literal as written in the source, its Value the
+// decoded string. Strings may span lines. An invalid escape sequence
+// yields an ILLEGAL token.
+func (l *Lexer) string() token.Token {
+	tok := token.Token{
+		Type:   token.STRING,
+		File:   l.file,
+		Line:   l.line,
+		Column: l.column,
+	}
+	quote := l.here()
+	value := strings.Builder{}
+	l.advance()
+	for {
+		r := l.rune()
+		if r == eof {
+			tok.Type = token.ILLEGAL
+			l.fail(diag.Errorf(diag.UNTERMINATED_STRING, quote, "unterminated string"))
+			break
+		}
+		if r == '"' {
+			l.advance()
+			break
 		}
+		if r == '\\' {
+			backslash := l.here()
+			if decoded, ok := l.escape(); ok {
+				value.WriteString(decoded)
+			} else {
+				tok.Type = token.ILLEGAL
+				backslash.End = l.base + l.offset
+				l.fail(diag.Errorf(diag.INVALID_ESCAPE, backslash, "invalid escape sequence"))
+			}
+			continue
+		}
+		value.WriteRune(r)
+		l.advance()
 	}
+	tok.Value = value.String()
+	return tok
+}
 
-	if tok.None {
-		tok = option.Some(token.Token{
-			Type:    token.ILLEGAL,
-			Literal: string(l.rune()),
-			File:    l.file,
-			Line:    l.line,
-			Column:  l.column,
-		})
-		l.position++
-		l.column++
+// escape decodes the escape sequence starting with the backslash at the
+// current position.
+func (l *Lexer) escape() (string, bool) {
+	l.advance()
+	r := l.rune()
+	switch r {
+	case 'n':
+		l.advance()
+		return "\n", true
+	case 't':
+		l.advance()
+		return "\t", true
+	case 'r':
+		l.advance()
+		return "\r", true
+	case '0':
+		l.advance()
+		return "\x00", true
+	case '\\', '"':
+		l.advance()
+		return string(r), true
+	case 'x':
+		l.advance()
+		start := l.offset
+		for range 2 {
+			if digitValue(l.rune()) >= 16 {
+				return "", false
+			}
+			l.advance()
+		}
+		b, _ := strconv.ParseUint(l.input[start:l.offset], 16, 8)
+		return string([]byte{byte(b)}), true
+	case 'u':
+		l.advance()
+		if l.rune() != '{' {
+			return "", false
+		}
+		l.advance()
+		start := l.offset
+		for l.rune() != '}' {
+			if digitValue(l.rune()) >= 16 || l.offset-start == 6 {
+				return "", false
+			}
+			l.advance()
+		}
+		digits := l.input[start:l.offset]
+		l.advance()
+		if digits == "" {
+			return "", false
+		}
+		cp, _ := strconv.ParseUint(digits, 16, 32)
+		if cp > unicode.MaxRune || (cp >= 0xD800 && cp <= 0xDFFF) {
+			return "", false
+		}
+		return string(rune(cp)), true
+	default:
+		return "", false
 	}
+}
 
-	return tok.Val
+// comment scans a line comment or a block comment starting at the
+// current position. Block comments nest. An unterminated block comment
+// yields an ILLEGAL token.
+func (l *Lexer) comment() token.Token {
+	tok := token.Token{
+		Type:   token.COMMENT,
+		File:   l.file,
+		Line:   l.line,
+		Column: l.column,
+	}
+	if l.peek() == '/' {
+		for r := l.rune(); r != '\n' && r != eof; r = l.rune() {
+			l.advance()
+		}
+	} else {
+		open := l.here()
+		open.End++
+		depth := 0
+		for {
+			r := l.rune()
+			if r == eof {
+				tok.Type = token.ILLEGAL
+				l.fail(diag.Errorf(diag.UNTERMINATED_COMMENT, open, "unterminated block comment"))
+				break
+			}
+			if r == '/' && l.peek() == '*' {
+				depth++
+				l.advance()
+				l.advance()
+				continue
+			}
+			if r == '*' && l.peek() == '/' {
+				depth--
+				l.advance()
+				l.advance()
+				if depth == 0 {
+					break
+				}
+				continue
+			}
+			l.advance()
+		}
+	}
+	return tok
+}
+
+// Line returns line n of the input, so that the lexer can be the
+// diag.Source of the diagnostics found in it. When reading from an
+// io.Reader, only the current line and the keptLines lines before it
+// are available, and the current one only as far as it is read.
+func (l *Lexer) Line(n int) (string, bool) {
+	if !l.streaming {
+		return diag.Text(l.input).Line(n)
+	}
+	if n == l.line {
+		line := l.input[l.lineStart:]
+		if i := strings.IndexByte(line, '\n'); i >= 0 {
+			line = line[:i]
+		}
+		return line, true
+	}
+	if recent := l.recent[n%keptLines]; n > 0 && recent.n == n {
+		return recent.text, true
+	}
+	return "", false
+}
+
+// fail records why the token being scanned is ILLEGAL. Only the first
+// reason is kept, as it is the one Analyze reports.
+func (l *Lexer) fail(d diag.Diagnostic) {
+	if l.err == nil {
+		l.err = d
+	}
 }
 
+// rune returns the rune at the current position, or eof.
 func (l *Lexer) rune() rune {
-	if l.position >= len([]rune(l.input)) {
-		return 0
+	if l.offset < len(l.input) && l.input[l.offset] < utf8.RuneSelf {
+		return rune(l.input[l.offset])
+	}
+	return l.runeAt(l.offset)
+}
+
+// peek returns the rune after the current one, or eof.
+func (l *Lexer) peek() rune {
+	if l.runeAt(l.offset) == eof {
+		return eof
 	}
-	return []rune(l.input)[l.position]
+	_, size := utf8.DecodeRuneInString(l.input[l.offset:])
+	return l.runeAt(l.offset + size)
 }
 
-func (l *Lexer) field() string {
-	fields := strings.FieldsFunc(l.input[l.position:], func(r rune) bool {
-		doSplit := unicode.IsSpace(r) || unicode.IsSymbol(r) || unicode.IsPunct(r)
-		doSplit = doSplit && r != '_'
-		doSplit = doSplit && r != '.'
-		return doSplit
-	})
-	if len(fields) > 0 {
-		return fields[0]
-	}
-	return ""
+// runeAt returns the rune at the given offset, or eof.
+func (l *Lexer) runeAt(offset int) rune {
+	for !utf8.FullRuneInString(l.input[min(offset, len(l.input)):]) && l.fill() {
+	}
+	if offset >= len(l.input) {
+		return eof
+	}
+	r, _ := utf8.DecodeRuneInString(l.input[offset:])
+	return r
+}
+
+// fill appends the next chunk of the reader to the input and reports
+// whether there was more to read. A read error is recorded and ends the
+// input.
+func (l *Lexer) fill() bool {
+	if l.reader == nil {
+		return false
+	}
+	buf := make([]byte, max(chunkSize, len(l.input)))
+	n, err := io.ReadAtLeast(l.reader, buf, 1)
+	l.input += string(buf[:n])
+	if err != nil {
+		if err != io.EOF {
+			l.fail(diag.Errorf(diag.READ_ERROR, l.here(), "%v", err))
+		}
+		l.reader = nil
+	}
+	return n > 0
+}
+
+// here returns the span of the rune at the current position, which is
+// empty at the end of the input.
+func (l *Lexer) here() token.Span {
+	size := 0
+	if l.offset < len(l.input) {
+		_, size = utf8.DecodeRuneInString(l.input[l.offset:])
+	}
+	return token.Span{
+		File:   l.file,
+		Line:   l.line,
+		Column: l.column,
+		Offset: l.base + l.offset,
+		End:    l.base + l.offset + size,
+	}
+}
+
+// advance moves past the rune at the current position.
+func (l *Lexer) advance() {
+	if l.offset >= len(l.input) {
+		return
+	}
+	r, size := utf8.DecodeRuneInString(l.input[l.offset:])
+	l.offset += size
+	if r == '\n' {
+		if l.streaming {
+			l.recent[l.line%keptLines] = sourceLine{l.line, strings.Clone(l.input[l.lineStart : l.offset-1])}
+		}
+		l.lineStart = l.offset
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
+	}
 }
@@ -1,9 +1,15 @@
 package lexer_test
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"reflect"
+	gostrings "strings"
 	"testing"
+	"testing/iotest"
 
+	"github.com/tobiashort/monkey/diag"
 	"github.com/tobiashort/monkey/lexer"
 	"github.com/tobiashort/monkey/token"
 
@@ -19,6 +25,8 @@ func test(t *testing.T, input string, expectedTokens []token.Token) {
 
 	for i, expectedToken := range expectedTokens {
 		actualToken := tokens[i]
+		// spans are checked by TestAnalyze21, leave them out elsewhere
+		actualToken.Offset, actualToken.End = 0, 0
 		if !reflect.DeepEqual(expectedToken, actualToken) {
 			t.Fatalf(
 				strings.Dedent(`
@@ -101,15 +109,15 @@ func TestAnalyze2(t *testing.T) {
 }
 
 func TestAnalyze3(t *testing.T) {
-	input := `==!!=-/*<><=>=&&||&|`
+	input := `==!!=-*/<><=>=&&||&|`
 
 	expectedTokens := []token.Token{
 		{Type: token.EQUAL, Literal: "==", File: "", Line: 1, Column: 1},
 		{Type: token.BANG, Literal: "!", File: "", Line: 1, Column: 3},
 		{Type: token.NOT_EQUAL, Literal: "!=", File: "", Line: 1, Column: 4},
 		{Type: token.MINUS, Literal: "-", File: "", Line: 1, Column: 6},
-		{Type: token.SLASH, Literal: "/", File: "", Line: 1, Column: 7},
-		{Type: token.ASTERISK, Literal: "*", File: "", Line: 1, Column: 8},
+		{Type: token.ASTERISK, Literal: "*", File: "", Line: 1, Column: 7},
+		{Type: token.SLASH, Literal: "/", File: "", Line: 1, Column: 8},
 		{Type: token.LT, Literal: "<", File: "", Line: 1, Column: 9},
 		{Type: token.GT, Literal: ">", File: "", Line: 1, Column: 10},
 		{Type: token.LEQT, Literal: "<=", File: "", Line: 1, Column: 11},
@@ -181,7 +189,7 @@ func TestAnalyze6(t *testing.T) {
 			|12.3`)
 
 	expectedTokens := []token.Token{
-		{Type: token.STRING, Literal: "\"foo\"", File: "", Line: 1, Column: 1},
+		{Type: token.STRING, Literal: "\"foo\"", Value: "foo", File: "", Line: 1, Column: 1},
 		{Type: token.INT, Literal: "0", File: "", Line: 1, Column: 7},
 		{Type: token.FLOAT, Literal: "12.3", File: "", Line: 2, Column: 1},
 		{Type: token.EOF, Literal: "", File: "", Line: 2, Column: 5},
@@ -189,3 +197,390 @@ func TestAnalyze6(t *testing.T) {
 
 	test(t, input, expectedTokens)
 }
+
+func TestAnalyze7(t *testing.T) {
+	input := "true false null nullable"
+
+	expectedTokens := []token.Token{
+		{Type: token.TRUE, Literal: "true", File: "", Line: 1, Column: 1},
+		{Type: token.FALSE, Literal: "false", File: "", Line: 1, Column: 6},
+		{Type: token.NULL, Literal: "null", File: "", Line: 1, Column: 12},
+		{Type: token.IDENT, Literal: "nullable", File: "", Line: 1, Column: 17},
+		{Type: token.EOF, Literal: "", File: "", Line: 1, Column: 25},
+	}
+
+	test(t, input, expectedTokens)
+}
+
+func TestAnalyze8(t *testing.T) {
+	input := "a[1:2]"
+
+	expectedTokens := []token.Token{
+		{Type: token.IDENT, Literal: "a", File: "", Line: 1, Column: 1},
+		{Type: token.LBRACKET, Literal: "[", File: "", Line: 1, Column: 2},
+		{Type: token.INT, Literal: "1", File: "", Line: 1, Column: 3},
+		{Type: token.COLON, Literal: ":", File: "", Line: 1, Column: 4},
+		{Type: token.INT, Literal: "2", File: "", Line: 1, Column: 5},
+		{Type: token.RBRACKET, Literal: "]", File: "", Line: 1, Column: 6},
+		{Type: token.EOF, Literal: "", File: "", Line: 1, Column: 7},
+	}
+
+	test(t, input, expectedTokens)
+}
+
+func TestAnalyze9(t *testing.T) {
+	input := strings.Dedent(`a // line comment
+	                        |/* block /* nested
+	                        |   */ comment */ b / c`)
+
+	expectedTokens := []token.Token{
+		{Type: token.IDENT, Literal: "a", File: "", Line: 1, Column: 1},
+		{Type: token.IDENT, Literal: "b", File: "", Line: 3, Column: 18},
+		{Type: token.SLASH, Literal: "/", File: "", Line: 3, Column: 20},
+		{Type: token.IDENT, Literal: "c", File: "", Line: 3, Column: 22},
+		{Type: token.EOF, Literal: "", File: "", Line: 3, Column: 23},
+	}
+
+	test(t, input, expectedTokens)
+}
+
+func TestAnalyze10(t *testing.T) {
+	input := strings.Dedent(`a // line comment
+	                        |/* block */ b`)
+
+	expectedTokens := []token.Token{
+		{Type: token.IDENT, Literal: "a", File: "", Line: 1, Column: 1, Offset: 0, End: 1},
+		{Type: token.COMMENT, Literal: "// line comment", File: "", Line: 1, Column: 3, Offset: 2, End: 17},
+		{Type: token.COMMENT, Literal: "/* block */", File: "", Line: 2, Column: 1, Offset: 18, End: 29},
+		{Type: token.IDENT, Literal: "b", File: "", Line: 2, Column: 13, Offset: 30, End: 31},
+		{Type: token.EOF, Literal: "", File: "", Line: 2, Column: 14, Offset: 31, End: 31},
+	}
+
+	tokens, err := lexer.New("", input).KeepComments().Analyze()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expectedTokens, tokens) {
+		t.Fatalf(
+			strings.Dedent(`
+			               |Expected: %+v
+			               |Got:      %+v`),
+			expectedTokens,
+			tokens)
+	}
+}
+
+func TestAnalyze11(t *testing.T) {
+	_, err := lexer.New("", "a /* /* */").Analyze()
+	if err == nil {
+		t.Fatal("Expected error for unterminated block comment")
+	}
+}
+
+func TestAnalyze12(t *testing.T) {
+	input := `"a\n\t\r\\\"\0\x41\u{1F600}" "multi
+line" x`
+
+	expectedTokens := []token.Token{
+		{Type: token.STRING, Literal: `"a\n\t\r\\\"\0\x41\u{1F600}"`, Value: "a\n\t\r\\\"\x00A😀", File: "", Line: 1, Column: 1},
+		{Type: token.STRING, Literal: "\"multi\nline\"", Value: "multi\nline", File: "", Line: 1, Column: 30},
+		{Type: token.IDENT, Literal: "x", File: "", Line: 2, Column: 7},
+		{Type: token.EOF, Literal: "", File: "", Line: 2, Column: 8},
+	}
+
+	test(t, input, expectedTokens)
+}
+
+func TestAnalyze13(t *testing.T) {
+	for _, input := range []string{`"\q"`, `"\x4"`, `"\u{}"`, `"\u{110000}"`, `"\u{D800}"`, `"\u41"`} {
+		if _, err := lexer.New("", input).Analyze(); err == nil {
+			t.Fatalf("Expected error for invalid escape sequence in %s", input)
+		}
+	}
+}
+
+func TestAnalyze14(t *testing.T) {
+	inputs := []string{
+		"let a = \"foo;\nlet b = 1;",
+		"a /* /* */",
+		`"\q"`,
+	}
+	expectedErrors := []string{
+		"test.mk:1:9: unterminated string",
+		"test.mk:1:3: unterminated block comment",
+		"test.mk:1:2: invalid escape sequence",
+	}
+	expectedCodes := []diag.Code{
+		diag.UNTERMINATED_STRING,
+		diag.UNTERMINATED_COMMENT,
+		diag.INVALID_ESCAPE,
+	}
+
+	for i, input := range inputs {
+		tokens, err := lexer.New("test.mk", input).Analyze()
+		if err == nil {
+			t.Fatalf("Expected error for %q", input)
+		}
+		if actual := err.Error(); actual != expectedErrors[i] {
+			t.Fatalf("Expected: %s, Got: %s", expectedErrors[i], actual)
+		}
+		var d diag.Diagnostic
+		if !errors.As(err, &d) || d.Code != expectedCodes[i] {
+			t.Fatalf("Expected diagnostic with code %s, got %#v", expectedCodes[i], err)
+		}
+		if last := tokens[len(tokens)-1]; last.Type != token.ILLEGAL {
+			t.Fatalf("Expected last token to be ILLEGAL, got %s", last.Type)
+		}
+	}
+}
+
+func TestAnalyze15(t *testing.T) {
+	input := `let ä = "ü"; äb + 1`
+
+	expectedTokens := []token.Token{
+		{Type: token.LET, Literal: "let", File: "", Line: 1, Column: 1},
+		{Type: token.IDENT, Literal: "ä", File: "", Line: 1, Column: 5},
+		{Type: token.ASSIGN, Literal: "=", File: "", Line: 1, Column: 7},
+		{Type: token.STRING, Literal: `"ü"`, Value: "ü", File: "", Line: 1, Column: 9},
+		{Type: token.SEMICOLON, Literal: ";", File: "", Line: 1, Column: 12},
+		{Type: token.IDENT, Literal: "äb", File: "", Line: 1, Column: 14},
+		{Type: token.PLUS, Literal: "+", File: "", Line: 1, Column: 17},
+		{Type: token.INT, Literal: "1", File: "", Line: 1, Column: 19},
+		{Type: token.EOF, Literal: "", File: "", Line: 1, Column: 20},
+	}
+
+	test(t, input, expectedTokens)
+}
+
+func TestAnalyze16(t *testing.T) {
+	input := "let größe = \"ünïcode\"; // ✓\n/* ☃ */ größe + 1.5"
+
+	expectedTokens, err := lexer.New("", input).KeepComments().Analyze()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := iotest.OneByteReader(gostrings.NewReader(input))
+	tokens, err := lexer.NewReader("", r).KeepComments().Analyze()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(expectedTokens, tokens) {
+		t.Fatalf(
+			strings.Dedent(`
+			               |Expected: %+v
+			               |Got:      %+v`),
+			expectedTokens,
+			tokens)
+	}
+}
+
+func TestAnalyze17(t *testing.T) {
+	l := lexer.NewReader("", gostrings.NewReader("a b # c"))
+
+	var types []token.TokenType
+	var err error
+	for tok, e := range l.All() {
+		types = append(types, tok.Type)
+		err = e
+	}
+
+	expectedTypes := []token.TokenType{token.IDENT, token.IDENT, token.ILLEGAL}
+	if !reflect.DeepEqual(expectedTypes, types) {
+		t.Fatalf("Expected: %v, Got: %v", expectedTypes, types)
+	}
+	if err == nil {
+		t.Fatal("Expected error for illegal token")
+	}
+	if _, err := l.Next(); err == nil {
+		t.Fatal("Expected error to be kept")
+	}
+}
+
+func TestAnalyze18(t *testing.T) {
+	r := io.MultiReader(gostrings.NewReader("let a"), iotest.ErrReader(errors.New("broken pipe")))
+	_, err := lexer.NewReader("test.mk", r).Analyze()
+	if err == nil {
+		t.Fatal("Expected read error")
+	}
+	if expected, actual := "test.mk:1:6: broken pipe", err.Error(); actual != expected {
+		t.Fatalf("Expected: %s, Got: %s", expected, actual)
+	}
+}
+
+func TestAnalyze19(t *testing.T) {
+	input := `0 0xFF 0o17 0B1010 1_000 3.25 1e9 2.5E-3 0x_1`
+
+	expectedTokens := []token.Token{
+		{Type: token.INT, Literal: "0", File: "", Line: 1, Column: 1},
+		{Type: token.INT, Literal: "0xFF", File: "", Line: 1, Column: 3},
+		{Type: token.INT, Literal: "0o17", File: "", Line: 1, Column: 8},
+		{Type: token.INT, Literal: "0B1010", File: "", Line: 1, Column: 13},
+		{Type: token.INT, Literal: "1_000", File: "", Line: 1, Column: 20},
+		{Type: token.FLOAT, Literal: "3.25", File: "", Line: 1, Column: 26},
+		{Type: token.FLOAT, Literal: "1e9", File: "", Line: 1, Column: 31},
+		{Type: token.FLOAT, Literal: "2.5E-3", File: "", Line: 1, Column: 35},
+		{Type: token.INT, Literal: "0x_1", File: "", Line: 1, Column: 42},
+		{Type: token.EOF, Literal: "", File: "", Line: 1, Column: 46},
+	}
+
+	test(t, input, expectedTokens)
+}
+
+func TestAnalyze20(t *testing.T) {
+	inputs := []string{
+		"a = 1.2.3;",
+		"a = 0xFG;",
+		"a = 0b102;",
+		"a = 1__0;",
+		"a = 1_;",
+		"a = 0x;",
+		"a = 1.;",
+		"a = 1e+;",
+		"a = 012;",
+		"a = 9223372036854775808;",
+		"a = 1e400;",
+		"a = 12abc;",
+	}
+	expectedErrors := []string{
+		"test.mk:1:8: unexpected '.' in decimal literal",
+		"test.mk:1:8: invalid digit 'G' in hexadecimal literal",
+		"test.mk:1:9: invalid digit '2' in binary literal",
+		"test.mk:1:6: '_' must separate successive digits",
+		"test.mk:1:6: '_' must separate successive digits",
+		"test.mk:1:7: hexadecimal literal has no digits",
+		"test.mk:1:7: expected digit after '.'",
+		"test.mk:1:8: exponent has no digits",
+		"test.mk:1:5: leading zeros are not allowed in decimal literals",
+		"test.mk:1:5: integer literal out of range",
+		"test.mk:1:5: float literal out of range",
+		"test.mk:1:7: invalid digit 'a' in decimal literal",
+	}
+
+	for i, input := range inputs {
+		tokens, err := lexer.New("test.mk", input).Analyze()
+		if err == nil {
+			t.Fatalf("Expected error for %q", input)
+		}
+		if actual := err.Error(); actual != expectedErrors[i] {
+			t.Fatalf("Expected: %s, Got: %s", expectedErrors[i], actual)
+		}
+		if last := tokens[len(tokens)-1]; last.Type != token.ILLEGAL {
+			t.Fatalf("Expected last token to be ILLEGAL, got %s", last.Type)
+		}
+	}
+}
+
+func TestAnalyze21(t *testing.T) {
+	input := "let ä = \"ü\\n\";\n0x_1F >= äb"
+
+	expectedSpans := []token.Span{
+		{File: "test.mk", Line: 1, Column: 1, Offset: 0, End: 3},
+		{File: "test.mk", Line: 1, Column: 5, Offset: 4, End: 6},
+		{File: "test.mk", Line: 1, Column: 7, Offset: 7, End: 8},
+		{File: "test.mk", Line: 1, Column: 9, Offset: 9, End: 15},
+		{File: "test.mk", Line: 1, Column: 14, Offset: 15, End: 16},
+		{File: "test.mk", Line: 2, Column: 1, Offset: 17, End: 22},
+		{File: "test.mk", Line: 2, Column: 7, Offset: 23, End: 25},
+		{File: "test.mk", Line: 2, Column: 10, Offset: 26, End: 29},
+		{File: "test.mk", Line: 2, Column: 12, Offset: 29, End: 29},
+	}
+
+	for _, l := range []*lexer.Lexer{
+		lexer.New("test.mk", input),
+		lexer.NewReader("test.mk", iotest.OneByteReader(gostrings.NewReader(input))),
+	} {
+		tokens, err := l.Analyze()
+		if err != nil {
+			t.Fatal(err)
+		}
+		spans := make([]token.Span, len(tokens))
+		for i, tok := range tokens {
+			spans[i] = tok.Span()
+			if tok.Literal != input[tok.Offset:tok.End] {
+				t.Fatalf("Expected literal %q to match its span, got %q", tok.Literal, input[tok.Offset:tok.End])
+			}
+		}
+		if !reflect.DeepEqual(expectedSpans, spans) {
+			t.Fatalf(
+				strings.Dedent(`
+				               |Expected: %+v
+				               |Got:      %+v`),
+				expectedSpans,
+				spans)
+		}
+	}
+}
+
+func TestAnalyze22(t *testing.T) {
+	var b gostrings.Builder
+	for i := 1; i <= 100; i++ {
+		fmt.Fprintf(&b, "let a%d = %d;\r\n", i, i)
+	}
+	b.WriteString("let last = \"")
+	input := b.String()
+
+	for _, l := range []*lexer.Lexer{
+		lexer.New("test.mk", input),
+		lexer.NewReader("test.mk", iotest.OneByteReader(gostrings.NewReader(input))),
+	} {
+		if _, err := l.Analyze(); err == nil {
+			t.Fatal("Expected an error")
+		}
+		for _, n := range []int{101, 100, 37} {
+			if line, ok := l.Line(n); !ok {
+				t.Fatalf("Expected line %d to be kept", n)
+			} else if expected := fmt.Sprintf("let a%d = %d;\r", n, n); n <= 100 && line != expected {
+				t.Fatalf("Expected: %q, Got: %q", expected, line)
+			} else if n == 101 && line != "let last = \"" {
+				t.Fatalf("Expected the last line, got %q", line)
+			}
+		}
+	}
+
+	// only recent lines are kept when reading
+	l := lexer.NewReader("test.mk", gostrings.NewReader(input))
+	l.Analyze()
+	if _, ok := l.Line(36); ok {
+		t.Fatal("Expected line 36 to be dropped")
+	}
+}
+
+func BenchmarkAnalyze(b *testing.B) {
+	line := "let größe = fn(x, y) { if (x <= y) { x * 2 } else { \"ünïcode\" } }; // comment\n"
+	input := gostrings.Repeat(line, (1<<20)/len(line))
+	b.SetBytes(int64(len(input)))
+	for b.Loop() {
+		if _, err := lexer.New("", input).Analyze(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func FuzzAnalyze(f *testing.F) {
+	for _, seed := range []string{
+		"let größe = fn(x, y) { x <= y };",
+		"\"a\\tb\\u00e4\" // comment\n/* comment */",
+		"0x_1F 0o17 0b101 1_000.5e-3 09 1e",
+		"\"unterminated",
+		"/* unterminated",
+		"@",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		tokens, err := lexer.New("fuzz.mk", input).KeepComments().Analyze()
+		if err != nil {
+			return
+		}
+		if tokens[len(tokens)-1].Type != token.EOF {
+			t.Fatalf("Expected EOF last, got %v", tokens[len(tokens)-1])
+		}
+		for _, tok := range tokens {
+			if tok.Offset < 0 || tok.Offset > tok.End || tok.End > len(input) {
+				t.Fatalf("Span out of range for %v", tok)
+			}
+		}
+	})
+}
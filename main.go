@@ -1,11 +1,95 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"os"
 
+	"github.com/tobiashort/monkey/diag"
+	"github.com/tobiashort/monkey/evaluator"
+	"github.com/tobiashort/monkey/lexer"
+	"github.com/tobiashort/monkey/object"
+	"github.com/tobiashort/monkey/parser"
 	"github.com/tobiashort/monkey/repl"
 )
 
 func main() {
-	repl.Start(os.Stdout, os.Stdin)
+	format := flag.String("format", "text", "format of syntax errors: text, json or sarif")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-format text|json|sarif] [file|-]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *format != "text" && *format != "json" && *format != "sarif" {
+		fmt.Fprintf(os.Stderr, "unknown format %q\n", *format)
+		flag.Usage()
+		os.Exit(2)
+	}
+	if flag.NArg() < 1 {
+		repl.Start(os.Stdout, os.Stdin)
+		return
+	}
+	if source, err := run(flag.Arg(0)); err != nil {
+		var diags diag.List
+		if errors.As(err, &diags) {
+			report(*format, diags, source)
+		} else {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// report writes the syntax errors of a script to stderr in format.
+func report(format string, diags diag.List, source diag.Source) {
+	var err error
+	switch format {
+	case "json":
+		err = diag.WriteJSON(os.Stderr, diags)
+	case "sarif":
+		err = diag.WriteSARIF(os.Stderr, diags, "monkey")
+	default:
+		diag.RenderAll(os.Stderr, diags, source)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+	}
+}
+
+// run evaluates the script in file, or the one piped to stdin if file
+// is "-". The script is lexed and parsed as it is read. If it has syntax
+// errors, run also returns the source to show them in: the file, read
+// again, or for stdin the lexer, which only keeps the lines read last,
+// so that errors further back are shown without their line.
+func run(file string) (diag.Source, error) {
+	name, input := "stdin", io.Reader(os.Stdin)
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		name, input = file, f
+	}
+
+	l := lexer.NewReader(name, input)
+	p := parser.NewStream(l)
+	ast, err := p.Parse()
+	if err != nil {
+		if file == "-" {
+			return l, err
+		}
+		if text, readErr := os.ReadFile(file); readErr == nil {
+			return diag.Text(text), err
+		}
+		return l, err
+	}
+
+	if result := evaluator.Eval(ast, object.NewEnvironment()); result != nil && result.Type() == object.ERROR {
+		return nil, fmt.Errorf("%s", result.(*object.Error).Message)
+	}
+	return nil, nil
 }
@@ -0,0 +1,60 @@
+package object
+
+import (
+	"io"
+	"os"
+)
+
+// Environment is a lexical scope. Lookups that miss in the scope itself
+// continue in the enclosing scope.
+type Environment struct {
+	store map[string]Object
+	outer *Environment
+	out   io.Writer
+}
+
+// NewEnvironment returns an outermost scope writing to standard output.
+func NewEnvironment() *Environment {
+	return &Environment{
+		store: make(map[string]Object),
+		outer: nil,
+		out:   os.Stdout,
+	}
+}
+
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	env := NewEnvironment()
+	env.outer = outer
+	env.out = nil
+	return env
+}
+
+// SetOutput sets where builtins like puts write to, in this scope and
+// the scopes it encloses.
+func (e *Environment) SetOutput(w io.Writer) {
+	e.out = w
+}
+
+// Output returns where builtins write to, which enclosed scopes take
+// from the scope enclosing them.
+func (e *Environment) Output() io.Writer {
+	if e.out == nil && e.outer != nil {
+		return e.outer.Output()
+	}
+	return e.out
+}
+
+func (e *Environment) Get(name string) (Object, bool) {
+	obj, ok := e.store[name]
+	if !ok && e.outer != nil {
+		return e.outer.Get(name)
+	}
+	return obj, ok
+}
+
+// Set binds name in this scope, shadowing any binding of the same name
+// in an enclosing scope.
+func (e *Environment) Set(name string, val Object) Object {
+	e.store[name] = val
+	return val
+}
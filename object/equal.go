@@ -0,0 +1,73 @@
+package object
+
+import "math"
+
+// Equal reports whether a and b are the same value. Values of different
+// types are never equal, except an integer and a float of the same
+// number. Scalars compare by value, functions and builtins by identity.
+// Arrays are equal if their elements are pairwise equal, hashes if they
+// map the same keys to equal values.
+func Equal(a, b Object) bool {
+	switch a := a.(type) {
+	case *Integer:
+		if b, ok := b.(*Float); ok {
+			return integral(b.Value) && int64(b.Value) == a.Value
+		}
+	case *Float:
+		if b, ok := b.(*Integer); ok {
+			return integral(a.Value) && int64(a.Value) == b.Value
+		}
+	}
+	if a.Type() != b.Type() {
+		return false
+	}
+	switch a := a.(type) {
+	case *Integer:
+		return a.Value == b.(*Integer).Value
+	case *Float:
+		return a.Value == b.(*Float).Value
+	case *String:
+		return a.Value == b.(*String).Value
+	case *Boolean:
+		return a.Value == b.(*Boolean).Value
+	case *Null:
+		return true
+	case *Array:
+		other := b.(*Array)
+		if len(a.Elements) != len(other.Elements) {
+			return false
+		}
+		for i := range a.Elements {
+			if !Equal(a.Elements[i], other.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	case *Hash:
+		other := b.(*Hash)
+		if len(a.Pairs) != len(other.Pairs) {
+			return false
+		}
+		for key, pair := range a.Pairs {
+			otherPair, ok := other.Pairs[key]
+			if !ok || !Equal(pair.Value, otherPair.Value) {
+				return false
+			}
+		}
+		return true
+	case *ReturnValue:
+		return Equal(a.Value, b.(*ReturnValue).Value)
+	case *YieldValue:
+		return Equal(a.Value, b.(*YieldValue).Value)
+	case *Error:
+		return a.Message == b.(*Error).Message
+	default:
+		return a == b
+	}
+}
+
+// integral reports whether f is a whole number in the range of int64,
+// which an Integer could hold exactly.
+func integral(f float64) bool {
+	return f >= math.MinInt64 && f < math.MaxInt64 && f == math.Trunc(f)
+}
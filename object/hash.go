@@ -0,0 +1,44 @@
+package object
+
+import "math"
+
+// HashKey identifies a hashable value exactly: values have equal hash
+// keys if and only if they are Equal, so a key never stands for more
+// than one value. Strings are identified by their Text rather than a
+// hash of it, which could collide.
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+	Text  string
+}
+
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+// HashKey of a float with a whole value is that of the equal Integer,
+// so that 1 and 1.0 are the same key. NaN is not Equal to itself, so
+// its key must not be used.
+func (f *Float) HashKey() HashKey {
+	if integral(f.Value) {
+		return HashKey{Type: INTEGER, Value: uint64(int64(f.Value))}
+	}
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	}
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+func (s *String) HashKey() HashKey {
+	return HashKey{Type: s.Type(), Text: s.Value}
+}
@@ -0,0 +1,165 @@
+package object
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tobiashort/monkey/ast"
+	"github.com/tobiashort/monkey/token"
+)
+
+type ObjectType = string
+
+const (
+	INTEGER  = "INTEGER"
+	FLOAT    = "FLOAT"
+	STRING   = "STRING"
+	BOOLEAN  = "BOOLEAN"
+	NULL     = "NULL"
+	ARRAY    = "ARRAY"
+	HASH     = "HASH"
+	FUNCTION = "FUNCTION"
+	BUILTIN  = "BUILTIN"
+	RETURN   = "RETURN"
+	YIELD    = "YIELD"
+	ERROR    = "ERROR"
+)
+
+type Object interface {
+	Type() ObjectType
+	Inspect() string
+}
+
+type Integer struct {
+	Value int64
+}
+
+func (i *Integer) Type() ObjectType { return INTEGER }
+func (i *Integer) Inspect() string  { return strconv.FormatInt(i.Value, 10) }
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT }
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		// keep floats distinguishable from integers
+		s += ".0"
+	}
+	return s
+}
+
+type String struct {
+	Value string
+}
+
+func (s *String) Type() ObjectType { return STRING }
+func (s *String) Inspect() string  { return strconv.Quote(s.Value) }
+
+type Boolean struct {
+	Value bool
+}
+
+func (b *Boolean) Type() ObjectType { return BOOLEAN }
+func (b *Boolean) Inspect() string  { return strconv.FormatBool(b.Value) }
+
+type Null struct{}
+
+func (n *Null) Type() ObjectType { return NULL }
+func (n *Null) Inspect() string  { return "null" }
+
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType { return ARRAY }
+func (a *Array) Inspect() string {
+	elements := make([]string, len(a.Elements))
+	for i, e := range a.Elements {
+		elements[i] = e.Inspect()
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+type Hash struct {
+	Pairs map[HashKey]HashPair
+}
+
+func (h *Hash) Type() ObjectType { return HASH }
+func (h *Hash) Inspect() string {
+	pairs := make([]string, 0, len(h.Pairs))
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair.Key.Inspect()+": "+pair.Value.Inspect())
+	}
+	// map iteration order is random, keep the output stable
+	sort.Strings(pairs)
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// Function is a closure: Env is the environment the function was
+// defined in, which its body is evaluated in when the function is called.
+type Function struct {
+	Name       string
+	Parameters []ast.IdentifierExpression
+	Body       ast.Block
+	Env        *Environment
+}
+
+func (f *Function) Type() ObjectType { return FUNCTION }
+func (f *Function) Inspect() string {
+	params := make([]string, len(f.Parameters))
+	for i, p := range f.Parameters {
+		params[i] = p.Identifier.Literal
+	}
+	if f.Name != "" {
+		return fmt.Sprintf("fn %s(%s)", f.Name, strings.Join(params, ", "))
+	}
+	return fmt.Sprintf("fn(%s)", strings.Join(params, ", "))
+}
+
+// BuiltinFunction is called with the environment of the call.
+type BuiltinFunction func(env *Environment, args ...Object) Object
+
+type Builtin struct {
+	Name string
+	Fn   BuiltinFunction
+}
+
+func (b *Builtin) Type() ObjectType { return BUILTIN }
+func (b *Builtin) Inspect() string  { return fmt.Sprintf("builtin %s", b.Name) }
+
+// ReturnValue wraps the value of a return statement while it unwinds
+// to the enclosing function call.
+type ReturnValue struct {
+	Value Object
+}
+
+func (r *ReturnValue) Type() ObjectType { return RETURN }
+func (r *ReturnValue) Inspect() string  { return r.Value.Inspect() }
+
+// YieldValue wraps the value of a yield statement while it unwinds
+// to the enclosing if expression. Span is that of the yield statement,
+// to report it if nothing catches it.
+type YieldValue struct {
+	Value Object
+	Span  token.Span
+}
+
+func (y *YieldValue) Type() ObjectType { return YIELD }
+func (y *YieldValue) Inspect() string  { return y.Value.Inspect() }
+
+type Error struct {
+	Message string
+}
+
+func (e *Error) Type() ObjectType { return ERROR }
+func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
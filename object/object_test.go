@@ -0,0 +1,109 @@
+package object_test
+
+import (
+	"testing"
+
+	"github.com/tobiashort/monkey/object"
+)
+
+func TestInspect(t *testing.T) {
+	objects := []object.Object{
+		&object.Integer{Value: -42},
+		&object.Float{Value: 1.5},
+		&object.Float{Value: 3},
+		&object.String{Value: "a\"b"},
+		&object.Boolean{Value: true},
+		&object.Null{},
+		&object.Error{Message: "boom"},
+	}
+	expected := []string{"-42", "1.5", "3.0", `"a\"b"`, "true", "null", "ERROR: boom"}
+
+	for i, obj := range objects {
+		if actual := obj.Inspect(); actual != expected[i] {
+			t.Fatalf("Expected: %s, Got: %s", expected[i], actual)
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	fn := &object.Function{}
+
+	equal := [][2]object.Object{
+		{&object.Integer{Value: 1}, &object.Integer{Value: 1}},
+		{&object.Float{Value: 1.5}, &object.Float{Value: 1.5}},
+		{&object.String{Value: "a"}, &object.String{Value: "a"}},
+		{&object.Boolean{Value: false}, &object.Boolean{Value: false}},
+		{&object.Null{}, &object.Null{}},
+		{fn, fn},
+		{&object.Integer{Value: 1}, &object.Float{Value: 1}},
+		{&object.Float{Value: -0.0}, &object.Integer{Value: 0}},
+		{
+			&object.Array{Elements: []object.Object{&object.Integer{Value: 1}}},
+			&object.Array{Elements: []object.Object{&object.Float{Value: 1}}},
+		},
+	}
+	for _, pair := range equal {
+		if !object.Equal(pair[0], pair[1]) {
+			t.Fatalf("Expected %s to equal %s", pair[0].Inspect(), pair[1].Inspect())
+		}
+	}
+
+	notEqual := [][2]object.Object{
+		{&object.Integer{Value: 1}, &object.Integer{Value: 2}},
+		{&object.Integer{Value: 1}, &object.String{Value: "1"}},
+		{&object.Boolean{Value: false}, &object.Null{}},
+		{&object.Function{}, &object.Function{}},
+		{&object.Integer{Value: 1}, &object.Float{Value: 1.5}},
+		// too large for a float to hold exactly
+		{&object.Integer{Value: 1<<53 + 1}, &object.Float{Value: 1 << 53}},
+	}
+	for _, pair := range notEqual {
+		if object.Equal(pair[0], pair[1]) {
+			t.Fatalf("Expected %s not to equal %s", pair[0].Inspect(), pair[1].Inspect())
+		}
+	}
+}
+
+func TestHashKey(t *testing.T) {
+	hello1 := &object.String{Value: "Hello World"}
+	hello2 := &object.String{Value: "Hello World"}
+	diff := &object.String{Value: "My name is johnny"}
+
+	if hello1.HashKey() != hello2.HashKey() {
+		t.Fatalf("strings with same content have different hash keys")
+	}
+	if hello1.HashKey() == diff.HashKey() {
+		t.Fatalf("strings with different content have same hash keys")
+	}
+
+	if (&object.Integer{Value: 1}).HashKey() == (&object.Boolean{Value: true}).HashKey() {
+		t.Fatalf("values of different types have same hash keys")
+	}
+
+	if (&object.Integer{Value: 2}).HashKey() != (&object.Float{Value: 2}).HashKey() {
+		t.Fatalf("equal integer and float have different hash keys")
+	}
+	if (&object.Float{Value: 2.5}).HashKey() == (&object.Float{Value: 2}).HashKey() {
+		t.Fatalf("floats with different values have same hash keys")
+	}
+}
+
+func TestHashKey2(t *testing.T) {
+	// keys hold the text itself, which unlike a hash of it cannot collide
+	a := &object.String{Value: "costarring"}
+	b := &object.String{Value: "liquid"}
+	if a.HashKey() == b.HashKey() {
+		t.Fatalf("different strings have same hash keys")
+	}
+
+	hash := &object.Hash{Pairs: map[object.HashKey]object.HashPair{
+		a.HashKey(): {Key: a, Value: &object.Integer{Value: 1}},
+		b.HashKey(): {Key: b, Value: &object.Integer{Value: 2}},
+	}}
+	if len(hash.Pairs) != 2 {
+		t.Fatalf("Expected 2 pairs, got %d", len(hash.Pairs))
+	}
+	if pair := hash.Pairs[(&object.String{Value: "liquid"}).HashKey()]; !object.Equal(pair.Value, &object.Integer{Value: 2}) {
+		t.Fatalf("Expected 2, got %v", pair.Value)
+	}
+}
@@ -0,0 +1,11 @@
+package parser
+
+import "github.com/tobiashort/monkey/token"
+
+// RegisterRightInfix registers t as a right associative binary operator
+// binding like a sum, and returns a function removing it again. None of
+// the operators of the language is right associative yet.
+func RegisterRightInfix(t token.TokenType) (unregister func()) {
+	registerInfix(t, sum, rightAssociative)
+	return func() { delete(operators, t) }
+}
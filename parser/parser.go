@@ -1,19 +1,44 @@
 package parser
 
 import (
+	"strconv"
+
 	"github.com/tobiashort/monkey/ast"
+	"github.com/tobiashort/monkey/diag"
 	"github.com/tobiashort/monkey/token"
-	"github.com/tobiashort/utils-go/errors"
 	"github.com/tobiashort/utils-go/slices"
 )
 
+// TokenStream supplies tokens one at a time, like *lexer.Lexer does.
+type TokenStream interface {
+	Next() (token.Token, error)
+}
+
 type Parser struct {
 	position int
 	tokens   []token.Token
+	stream   TokenStream
+	err      error
+	errors   diag.List
 	ast      ast.Ast
+	// braces is the number of braces opened by blocks and hash literals
+	// and not closed yet, and open the number of those around the
+	// statements being parsed
+	braces int
+	open   int
 }
 
 func New(tokens []token.Token) *Parser {
+	tokens = slices.Filter(tokens, func(t token.Token) bool { return t.Type != token.COMMENT })
+	if len(tokens) == 0 || tokens[len(tokens)-1].Type != token.EOF {
+		// the parser relies on the input ending with EOF
+		eof := token.Token{Type: token.EOF}
+		if len(tokens) > 0 {
+			last := tokens[len(tokens)-1]
+			eof.File, eof.Line, eof.Column, eof.Offset, eof.End = last.File, last.Line, last.Column, last.End, last.End
+		}
+		tokens = append(tokens, eof)
+	}
 	return &Parser{
 		position: 0,
 		tokens:   tokens,
@@ -21,88 +46,183 @@ func New(tokens []token.Token) *Parser {
 	}
 }
 
+// NewStream returns a parser that pulls tokens from s only as far as it
+// needs them, and forgets them once the statement they belong to is
+// parsed.
+func NewStream(s TokenStream) *Parser {
+	return &Parser{
+		position: 0,
+		tokens:   make([]token.Token, 0),
+		stream:   s,
+		ast:      make(ast.Ast, 0),
+	}
+}
+
+// Parse parses the whole input. It does not stop at a syntax error, but
+// skips past the next SEMICOLON or RBRACE that ends the statement in
+// error and carries on, to report all errors at once in a diag.List.
+// The returned ast then lacks the statements in error, but is otherwise
+// complete.
 func (p *Parser) Parse() (ast.Ast, error) {
+	p.parse()
+	if d, ok := p.err.(diag.Diagnostic); ok {
+		return p.ast, append(p.errors, d)
+	} else if p.err != nil {
+		return p.ast, p.err
+	}
+	if len(p.errors) > 0 {
+		return p.ast, p.errors
+	}
+	return p.ast, nil
+}
+
+func (p *Parser) parse() {
 	for p.token().Type != token.EOF {
-		switch p.token().Type {
+		if p.token().Type == token.RBRACE {
+			// a closing brace without an opening one
+			p.errors = append(p.errors, unexpected(p.token(), "statement"))
+			p.nextToken()
+		} else if err := p.endStatement(p.parseStatement()); err != nil {
+			break
+		}
+		p.discard()
+	}
+}
+
+// endStatement advances past the statement at the current position, or
+// records err, the error of the statement, and skips to the start of
+// the next one. It returns err only if the lexer failed, which explains
+// the error and leaves nothing more to parse.
+func (p *Parser) endStatement(err error) error {
+	if err == nil {
+		p.nextToken()
+		return nil
+	}
+	if p.err != nil {
+		return err
+	}
+	// every error the parser returns is a diagnostic
+	p.errors = append(p.errors, err.(diag.Diagnostic))
+	p.synchronize()
+	return nil
+}
+
+func (p *Parser) parseStatement() error {
+	switch p.token().Type {
+	case token.LBRACE:
+		if block, err := p.parseBlock(); err != nil {
+			return err
+		} else {
+			p.ast = append(p.ast, block)
+		}
+	case token.LET:
+		return p.parseLetStatement()
+	case token.RETURN:
+		return p.parseReturnStatement()
+	case token.YIELD:
+		return p.parseYieldStatement()
+	case token.IF:
+		return p.parseIfStatement()
+	case token.FUNCTION:
+		if p.hasNext() && p.peekToken().Type == token.LPAREN {
+			return p.parseExpressionStatement()
+		}
+		return p.parseFunction()
+	default:
+		if _, ok := prefixes[p.token().Type]; ok {
+			return p.parseExpressionStatement()
+		}
+		return unexpected(p.token(), "statement")
+	}
+	return nil
+}
+
+// synchronize skips from the token in error to the start of the next
+// statement, which follows a SEMICOLON or an RBRACE not nested in
+// braces opened after the error, and any SEMICOLON after it. RBRACEs
+// closing braces opened in the statement before the error are skipped
+// too, but synchronize stops at one closing the enclosing block, which
+// ends the statement as well, and skips a stray one at the top level,
+// which does too.
+func (p *Parser) synchronize() {
+	// braces opened in the statement are left behind
+	defer func() { p.braces = p.open }()
+	depth := 0
+	for t := p.token(); t.Type != token.EOF; t = p.token() {
+		switch t.Type {
 		case token.LBRACE:
-			if block, err := p.parseBlock(); err != nil {
-				return p.ast, err
+			depth++
+		case token.RBRACE:
+			if depth > 0 {
+				depth--
+				if depth == 0 {
+					// the braces may be part of an expression ended by
+					// a SEMICOLON, like a function literal
+					if p.nextToken().Type == token.SEMICOLON {
+						p.nextToken()
+					}
+					return
+				}
+			} else if p.braces > p.open {
+				p.braces--
+			} else if p.open > 0 {
+				return
 			} else {
-				p.ast = append(p.ast, block)
+				// a closing brace without an opening one
+				p.nextToken()
+				return
 			}
-		case token.LET:
-			if err := p.parseLetStatement(); err != nil {
-				return p.ast, err
-			}
-		case token.RETURN:
-			if err := p.parseReturnStatement(); err != nil {
-				return p.ast, err
-			}
-		case token.YIELD:
-			if err := p.parseYieldStatement(); err != nil {
-				return p.ast, err
-			}
-		case token.IF:
-			if err := p.parseIfStatement(); err != nil {
-				return p.ast, err
-			}
-		case token.FUNCTION:
-			if err := p.parseFunction(); err != nil {
-				return p.ast, err
-			}
-		case token.LPAREN, token.INT, token.FLOAT, token.STRING, token.IDENT:
-			if err := p.parseExpressionStatement(); err != nil {
-				return p.ast, err
+		case token.SEMICOLON:
+			if depth == 0 {
+				p.nextToken()
+				return
 			}
-		default:
-			return p.ast, errors.WithCtxf("%s:%d:%d: illegal token type %q", p.token().File, p.token().Line, p.token().Column, p.token().Type)
 		}
 		p.nextToken()
 	}
-	return p.ast, nil
 }
 
-func (p *Parser) parseBlock() (ast.Node, error) {
+func (p *Parser) parseBlock() (ast.Block, error) {
 	if err := p.expect(token.LBRACE); err != nil {
-		return nil, err
+		return ast.Block{}, err
 	}
-	depth := 1
 	lbrace := p.token()
-	tokens := make([]token.Token, 0)
-	for p.token().Type != token.EOF {
-		t := p.nextToken()
-		if t.Type == token.LBRACE {
-			depth++
-			tokens = append(tokens, t)
-		} else if t.Type == token.RBRACE {
-			depth--
-			if depth == 0 {
-				break
-			} else {
-				tokens = append(tokens, t)
-			}
-		} else {
-			tokens = append(tokens, t)
-		}
+	p.braces++
+	p.nextToken()
+	return p.parseBlockBody(lbrace, nil, nil)
+}
+
+// parseBlockBody parses the statements of the block opened by lbrace up
+// to its RBRACE. The block may start with the expression first, or with
+// a statement that failed with firstErr, if it is parsed already, see
+// parseBraces. Statements in error are recorded and skipped, as at the
+// top level.
+func (p *Parser) parseBlockBody(lbrace token.Token, first ast.Expression, firstErr error) (ast.Block, error) {
+	outer, open := p.ast, p.open
+	p.ast, p.open = make(ast.Ast, 0), p.braces
+	defer func() { p.ast, p.open = outer, open }()
+
+	if first != nil {
+		firstErr = p.endExpressionStatement(first)
 	}
-	if depth != 0 {
-		return nil, errors.WithCtxf("%s:%d:%d: unclosed block", lbrace.File, lbrace.Line, lbrace.Column)
+	if first != nil || firstErr != nil {
+		if err := p.endStatement(firstErr); err != nil {
+			return ast.Block{}, err
+		}
 	}
-	tokens = append(tokens, token.Token{
-		Type:    token.EOF,
-		Literal: "",
-		File:    tokens[len(tokens)-1].File,
-		Line:    tokens[len(tokens)-1].Line,
-		Column:  tokens[len(tokens)-1].Column + 1,
-	})
-	np := New(tokens)
-	nast, err := np.Parse()
-	if err != nil {
-		return nast, err
+	for p.token().Type != token.RBRACE {
+		if p.token().Type == token.EOF {
+			return ast.Block{}, diag.Errorf(diag.UNCLOSED_BLOCK, lbrace.Span(), "unclosed block").
+				WithNote(token.Span{}, "expected a matching \"}\" before the end of input")
+		}
+		if err := p.endStatement(p.parseStatement()); err != nil {
+			return ast.Block{}, err
+		}
 	}
+	p.braces--
 	return ast.Block{
-		Type: ast.BLOCK,
-		Ast:  nast,
+		Ast:   p.ast,
+		Range: lbrace.Span().To(p.token().Span()),
 	}, nil
 }
 
@@ -110,27 +230,29 @@ func (p *Parser) parseLetStatement() error {
 	if err := p.expect(token.LET); err != nil {
 		return err
 	}
+	let := p.token()
 	p.nextToken()
-	node := ast.LetStatement{
-		Type: ast.LET,
-	}
+	node := ast.LetStatement{}
 	if err := p.expect(token.IDENT); err != nil {
 		return err
 	}
 	node.Identifier = p.token()
 	p.nextToken()
-	p.expect(token.ASSIGN)
+	if err := p.expect(token.ASSIGN); err != nil {
+		return err
+	}
 	p.nextToken()
-	if expr, err := p.parseExpression(0); err != nil {
+	if expr, err := p.parseExpression(lowest); err != nil {
 		return err
 	} else {
 		node.Expression = expr
 	}
-	p.ast = append(p.ast, node)
 	p.nextToken()
 	if err := p.expect(token.SEMICOLON); err != nil {
 		return err
 	}
+	node.Range = let.Span().To(p.token().Span())
+	p.ast = append(p.ast, node)
 	return nil
 }
 
@@ -138,19 +260,20 @@ func (p *Parser) parseReturnStatement() error {
 	if err := p.expect(token.RETURN); err != nil {
 		return err
 	}
+	stmt := ast.ReturnStatement{}
+	keyword := p.token()
 	p.nextToken()
-	if expr, err := p.parseExpression(0); err != nil {
+	if expr, err := p.parseExpression(lowest); err != nil {
 		return err
 	} else {
-		p.ast = append(p.ast, ast.ReturnStatement{
-			Type:       ast.RETURN,
-			Expression: expr,
-		})
+		stmt.Expression = expr
 	}
 	p.nextToken()
 	if err := p.expect(token.SEMICOLON); err != nil {
 		return err
 	}
+	stmt.Range = keyword.Span().To(p.token().Span())
+	p.ast = append(p.ast, stmt)
 	return nil
 }
 
@@ -158,19 +281,20 @@ func (p *Parser) parseYieldStatement() error {
 	if err := p.expect(token.YIELD); err != nil {
 		return err
 	}
+	stmt := ast.YieldStatement{}
+	keyword := p.token()
 	p.nextToken()
-	if expr, err := p.parseExpression(0); err != nil {
+	if expr, err := p.parseExpression(lowest); err != nil {
 		return err
 	} else {
-		p.ast = append(p.ast, ast.YieldStatement{
-			Type:       ast.YIELD,
-			Expression: expr,
-		})
+		stmt.Expression = expr
 	}
 	p.nextToken()
 	if err := p.expect(token.SEMICOLON); err != nil {
 		return err
 	}
+	stmt.Range = keyword.Span().To(p.token().Span())
+	p.ast = append(p.ast, stmt)
 	return nil
 }
 
@@ -179,10 +303,10 @@ func (p *Parser) parseIfStatement() error {
 		return err
 	}
 	stmt := ast.IfStatement{
-		Type: ast.IF,
+		Range: p.token().Span(),
 	}
 	p.nextToken()
-	if cond, err := p.parseExpression(0); err != nil {
+	if cond, err := p.parseExpression(lowest); err != nil {
 		return err
 	} else {
 		stmt.Condition = cond
@@ -192,6 +316,7 @@ func (p *Parser) parseIfStatement() error {
 		return err
 	} else {
 		stmt.Consequence = cons
+		stmt.Range = stmt.Range.To(cons.Span())
 	}
 	if p.hasNext() && p.peekToken().Type == token.ELSE {
 		p.nextToken()
@@ -199,7 +324,8 @@ func (p *Parser) parseIfStatement() error {
 		if alt, err := p.parseBlock(); err != nil {
 			return err
 		} else {
-			stmt.Alternative = alt
+			stmt.Alternative = &alt
+			stmt.Range = stmt.Range.To(alt.Span())
 		}
 	}
 	p.ast = append(p.ast, stmt)
@@ -210,7 +336,7 @@ func (p *Parser) parseFunction() error {
 	if err := p.expect(token.FUNCTION); err != nil {
 		return err
 	}
-	f := ast.Function{Type: ast.FUNCTION}
+	f := ast.Function{Range: p.token().Span()}
 	p.nextToken()
 	if err := p.expect(token.IDENT); err != nil {
 		return err
@@ -230,168 +356,241 @@ func (p *Parser) parseFunction() error {
 		return err
 	} else {
 		f.Block = block
+		f.Range = f.Range.To(block.Span())
 	}
 	p.ast = append(p.ast, f)
 	return nil
 }
 
-func (p *Parser) parseParameters() ([]ast.Node, error) {
+// parseParameters parses the parameter list of a function, which are
+// identifiers.
+func (p *Parser) parseParameters() ([]ast.IdentifierExpression, error) {
+	return parseList(p, diag.UNCLOSED_PARAMETERS, "parameter list", func() (ast.IdentifierExpression, error) {
+		if err := p.expect(token.IDENT); err != nil {
+			return ast.IdentifierExpression{}, err
+		}
+		return ast.IdentifierExpression{Identifier: p.token()}, nil
+	})
+}
+
+// parseArguments parses the argument list of a call.
+func (p *Parser) parseArguments() ([]ast.Expression, error) {
+	return parseList(p, diag.UNCLOSED_ARGUMENTS, "argument list", func() (ast.Expression, error) {
+		return p.parseExpression(lowest)
+	})
+}
+
+// parseList parses a parenthesized, comma-separated list, which may be
+// empty, with parse parsing an element from its first token to its last
+// one, and stops at its RPAREN. A list left open is reported with
+// unclosed, naming the list what.
+func parseList[T any](p *Parser, unclosed diag.Code, what string, parse func() (T, error)) ([]T, error) {
 	if err := p.expect(token.LPAREN); err != nil {
 		return nil, err
 	}
-	startToken := p.token()
-	paramTokens := make([]token.Token, 0)
-	depth := 1
-	for {
-		p.nextToken()
+	lparen := p.token()
+	list := make([]T, 0)
+	p.nextToken()
+	for p.token().Type != token.RPAREN {
 		if p.token().Type == token.EOF {
-			break
-		} else if p.token().Type == token.RPAREN {
-			depth--
-			if depth == 0 {
-				break
-			} else {
-				paramTokens = append(paramTokens, p.token())
-			}
-		} else if p.token().Type == token.LPAREN {
-			depth++
-			paramTokens = append(paramTokens, p.token())
-		} else {
-			paramTokens = append(paramTokens, p.token())
+			return nil, diag.Errorf(unclosed, lparen.Span(), "unclosed %s", what).
+				WithNote(token.Span{}, "expected a matching \")\" before the end of input")
 		}
-	}
-	if depth != 0 {
-		return nil, errors.WithCtxf("%s:%d:%d: unclosed parameters", startToken.File, startToken.Line, startToken.Column)
-	}
-	paramTokensSplit := slices.Split(paramTokens, func(t token.Token) bool { return t.Type == token.COMMA })
-	params := make([]ast.Node, 0)
-	for _, ts := range paramTokensSplit {
-		if expr, err := New(ts).parseExpression(0); err != nil {
-			return params, err
+		if elem, err := parse(); err != nil {
+			return nil, err
 		} else {
-			params = append(params, expr)
+			list = append(list, elem)
+		}
+		p.nextToken()
+		if p.token().Type == token.COMMA {
+			p.nextToken()
+		} else if p.token().Type != token.EOF {
+			if err := p.expect(token.RPAREN); err != nil {
+				return nil, err
+			}
 		}
 	}
-	return params, nil
+	return list, nil
 }
 
 func (p *Parser) parseExpressionStatement() error {
-	node, err := p.parseExpression(0)
+	expr, err := p.parseExpression(lowest)
 	if err != nil {
 		return err
 	}
+	return p.endExpressionStatement(expr)
+}
+
+// endExpressionStatement completes the expression statement of expr,
+// which ends at the current position.
+func (p *Parser) endExpressionStatement(expr ast.Expression) error {
 	p.nextToken()
 	if err := p.expect(token.SEMICOLON); err != nil {
 		return err
 	}
-	node = ast.ExpressionStatement{
-		Type:       ast.EXPR,
-		Expression: node,
-	}
-	p.ast = append(p.ast, node)
+	p.ast = append(p.ast, ast.ExpressionStatement{
+		Expression: expr,
+		Range:      expr.Span().To(p.token().Span()),
+	})
 	return nil
 }
 
-func (p *Parser) parseExpression(bindingPower int) (ast.Node, error) {
-	var left ast.Node
-	switch p.token().Type {
-	case token.MINUS, token.BANG:
-		operator := p.token()
-		p.nextToken()
-		right, err := p.parseExpression(0)
-		if err != nil {
+func (p *Parser) parseArrayLiteral() (ast.Expression, error) {
+	if err := p.expect(token.LBRACKET); err != nil {
+		return nil, err
+	}
+	array := ast.ArrayLiteral{
+		Elements: make([]ast.Expression, 0),
+		Range:    p.token().Span(),
+	}
+	p.nextToken()
+	for p.token().Type != token.RBRACKET {
+		if elem, err := p.parseExpression(lowest); err != nil {
 			return nil, err
+		} else {
+			array.Elements = append(array.Elements, elem)
 		}
-		left = ast.UnaryExpression{
-			Type:     ast.UNARY,
-			Operator: operator,
-			Right:    right,
-		}
-	case token.LPAREN:
 		p.nextToken()
-		var err error
-		left, err = p.parseExpression(0)
-		if err != nil {
+		if p.token().Type == token.COMMA {
+			p.nextToken()
+		} else if err := p.expect(token.RBRACKET); err != nil {
 			return nil, err
 		}
-		p.nextToken()
-		if err := p.expect(token.RPAREN); err != nil {
+	}
+	array.Range = array.Range.To(p.token().Span())
+	return array, nil
+}
+
+// parseBraces parses a hash literal or a block, which both start with
+// an LBRACE. It is a hash literal if it is immediately closed or its
+// first expression is followed by a COLON, and a block otherwise. It is
+// a block too if it starts with a keyword or another LBRACE, which would
+// hardly make sense as the first key of a hash literal.
+func (p *Parser) parseBraces() (ast.Expression, error) {
+	if err := p.expect(token.LBRACE); err != nil {
+		return nil, err
+	}
+	lbrace := p.token()
+	p.braces++
+	p.nextToken()
+	switch p.token().Type {
+	case token.RBRACE:
+		return p.parseHashLiteral(lbrace, nil)
+	case token.LET, token.RETURN, token.YIELD, token.IF, token.FUNCTION, token.LBRACE:
+		return p.parseBlockBody(lbrace, nil, nil)
+	}
+	first, err := p.parseExpression(lowest)
+	if err != nil {
+		if p.token().Type == token.COLON {
+			// the first key of a hash literal is in error
 			return nil, err
 		}
-	case token.IDENT:
-		if p.hasNext() && p.peekToken().Type == token.LPAREN {
-			call := ast.CallExpression{
-				Type:       ast.CALL,
-				Identifier: p.token(),
-			}
-			p.nextToken()
-			if params, err := p.parseParameters(); err != nil {
+		// the first statement of a block is in error, which the block
+		// skips like the others
+		return p.parseBlockBody(lbrace, nil, err)
+	}
+	if p.hasNext() && p.peekToken().Type == token.COLON {
+		return p.parseHashLiteral(lbrace, first)
+	}
+	return p.parseBlockBody(lbrace, first, nil)
+}
+
+// parseHashLiteral parses the pairs of the hash literal opened by brace
+// up to its RBRACE. key is its first key if it is parsed already, see
+// parseBraces.
+func (p *Parser) parseHashLiteral(brace token.Token, key ast.Expression) (ast.Expression, error) {
+	hash := ast.HashLiteral{
+		Brace: brace,
+		Pairs: make([]ast.HashPair, 0),
+	}
+	for key != nil || p.token().Type != token.RBRACE {
+		pair := ast.HashPair{Key: key}
+		key = nil
+		if pair.Key == nil {
+			if expr, err := p.parseExpression(lowest); err != nil {
 				return nil, err
 			} else {
-				call.Parameters = params
-			}
-			left = call
-		} else {
-			left = ast.IdentifierExpression{
-				Type:       ast.IDENT,
-				Identifier: p.token(),
+				pair.Key = expr
 			}
 		}
-	case token.IF:
-		if expr, err := p.parseIfExpr(); err != nil {
+		p.nextToken()
+		if err := p.expect(token.COLON); err != nil {
 			return nil, err
-		} else {
-			left = expr
 		}
-	case token.FUNCTION:
-		if expr, err := p.parseFunctionExpr(); err != nil {
+		p.nextToken()
+		if value, err := p.parseExpression(lowest); err != nil {
 			return nil, err
 		} else {
-			left = expr
+			pair.Value = value
 		}
-	case token.STRING, token.FLOAT, token.INT:
-		left = ast.LiteralExpression{
-			Type:    ast.LITERAL,
-			Literal: p.token(),
+		hash.Pairs = append(hash.Pairs, pair)
+		p.nextToken()
+		if p.token().Type == token.COMMA {
+			p.nextToken()
+		} else if err := p.expect(token.RBRACE); err != nil {
+			return nil, err
 		}
-	default:
-		return nil, errors.WithCtxf("%s:%d:%d: illegal token type %q", p.token().File, p.token().Line, p.token().Column, p.token().Type)
 	}
+	p.braces--
+	hash.Range = hash.Brace.Span().To(p.token().Span())
+	return hash, nil
+}
 
-	for p.hasNext() {
-		nextBindingPower, err := token.BindingPower(p.peekToken())
-		if err != nil {
+func (p *Parser) parseIndexExpression(left ast.Expression) (ast.Expression, error) {
+	if err := p.expect(token.LBRACKET); err != nil {
+		return nil, err
+	}
+	bracket := p.token()
+	var low ast.Expression
+	p.nextToken()
+	if p.token().Type != token.COLON {
+		if expr, err := p.parseExpression(lowest); err != nil {
 			return nil, err
+		} else {
+			low = expr
 		}
-		if nextBindingPower <= bindingPower {
-			break
-		}
-		operator := p.nextToken()
 		p.nextToken()
-		right, err := p.parseExpression(nextBindingPower)
-		if err != nil {
+	}
+	if p.token().Type != token.COLON {
+		if err := p.expect(token.RBRACKET); err != nil {
 			return nil, err
 		}
-		left = ast.BinaryExpression{
-			Type:     ast.BINARY,
-			Left:     left,
-			Operator: operator,
-			Right:    right,
+		return ast.IndexExpression{
+			Left:    left,
+			Bracket: bracket,
+			Index:   low,
+			Range:   left.Span().To(p.token().Span()),
+		}, nil
+	}
+	slice := ast.SliceExpression{
+		Left:    left,
+		Bracket: bracket,
+		Low:     low,
+	}
+	p.nextToken()
+	if p.token().Type != token.RBRACKET {
+		if expr, err := p.parseExpression(lowest); err != nil {
+			return nil, err
+		} else {
+			slice.High = expr
 		}
+		p.nextToken()
 	}
-
-	return left, nil
+	if err := p.expect(token.RBRACKET); err != nil {
+		return nil, err
+	}
+	slice.Range = left.Span().To(p.token().Span())
+	return slice, nil
 }
 
-func (p *Parser) parseIfExpr() (ast.Node, error) {
+func (p *Parser) parseIfExpr() (ast.Expression, error) {
 	if err := p.expect(token.IF); err != nil {
 		return nil, err
 	}
-	expr := ast.IfExpression{
-		Type: ast.IFEXPR,
-	}
+	ifToken := p.token()
+	expr := ast.IfExpression{}
 	p.nextToken()
-	if cond, err := p.parseExpression(0); err != nil {
+	if cond, err := p.parseExpression(lowest); err != nil {
 		return nil, err
 	} else {
 		expr.Condition = cond
@@ -409,17 +608,20 @@ func (p *Parser) parseIfExpr() (ast.Node, error) {
 			return nil, err
 		} else {
 			expr.Alternative = alt
+			expr.Range = ifToken.Span().To(alt.Span())
 		}
+	} else {
+		return nil, diag.Errorf(diag.MISSING_ELSE, ifToken.Span(), "if expression without else branch")
 	}
 	return expr, nil
 }
 
-func (p *Parser) parseFunctionExpr() (ast.Node, error) {
+func (p *Parser) parseFunctionExpr() (ast.Expression, error) {
 	if err := p.expect(token.FUNCTION); err != nil {
 		return nil, err
 	}
 	f := ast.FunctionExpression{
-		Type: ast.FNEXPR,
+		Range: p.token().Span(),
 	}
 	p.nextToken()
 	if params, err := p.parseParameters(); err != nil {
@@ -435,6 +637,7 @@ func (p *Parser) parseFunctionExpr() (ast.Node, error) {
 		return nil, err
 	} else {
 		f.Block = block
+		f.Range = f.Range.To(block.Span())
 	}
 	return f, nil
 }
@@ -442,24 +645,83 @@ func (p *Parser) parseFunctionExpr() (ast.Node, error) {
 func (p *Parser) expect(tokenType token.TokenType) error {
 	t := p.token()
 	if t.Type != tokenType {
-		return errors.WithCtxf("parse error %s %d:%d: got %q, expected %q", t.File, t.Line, t.Column, t.Type, tokenType)
+		if tokenType == token.IDENT {
+			return unexpected(t, "identifier")
+		}
+		return unexpected(t, strconv.Quote(tokenType))
 	}
 	return nil
 }
 
 func (p *Parser) token() token.Token {
+	p.fill(p.position)
 	return p.tokens[p.position]
 }
 
 func (p *Parser) hasNext() bool {
-	return p.position < len(p.tokens)-1
+	return p.fill(p.position + 1)
 }
 
 func (p *Parser) peekToken() token.Token {
+	p.fill(p.position + 1)
 	return p.tokens[p.position+1]
 }
 
+// nextToken advances to the next token and returns it. It stays at the
+// last token, which is EOF unless the lexer failed.
 func (p *Parser) nextToken() token.Token {
-	p.position++
-	return p.tokens[p.position]
+	if p.hasNext() {
+		p.position++
+	}
+	return p.token()
+}
+
+// fill pulls tokens from the stream until there is one at position i,
+// and reports whether there is. The stream is dropped after EOF or an
+// error, as it has nothing more to give. An error ends the input like
+// EOF does.
+func (p *Parser) fill(i int) bool {
+	for i >= len(p.tokens) && p.stream != nil {
+		t, err := p.stream.Next()
+		if t.Type != token.COMMENT {
+			p.tokens = append(p.tokens, t)
+		}
+		if err != nil && t.Type != token.EOF {
+			p.tokens = append(p.tokens, token.Token{
+				Type:   token.EOF,
+				File:   t.File,
+				Line:   t.Line,
+				Column: t.Column,
+				Offset: t.End,
+				End:    t.End,
+			})
+		}
+		if err != nil || t.Type == token.EOF {
+			p.err = err
+			p.stream = nil
+		}
+	}
+	return i < len(p.tokens)
+}
+
+// discard forgets the tokens before the current position when parsing
+// a stream, so that memory use does not grow with the input.
+func (p *Parser) discard() {
+	if p.stream == nil {
+		return
+	}
+	n := copy(p.tokens, p.tokens[p.position:])
+	clear(p.tokens[n:])
+	p.tokens = p.tokens[:n]
+	p.position = 0
+}
+
+// unexpected returns the diagnostic for token t found where what was
+// expected.
+func unexpected(t token.Token, what string) diag.Diagnostic {
+	got := strconv.Quote(t.Literal)
+	if t.Type == token.EOF {
+		got = "end of input"
+	}
+	return diag.Errorf(diag.UNEXPECTED_TOKEN, t.Span(), "expected %s, got %s", what, got)
 }
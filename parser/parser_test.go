@@ -1,12 +1,16 @@
 package parser_test
 
 import (
+	"errors"
 	"reflect"
+	gostrings "strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/tobiashort/utils-go/strings"
 
 	"github.com/tobiashort/monkey/ast"
+	"github.com/tobiashort/monkey/diag"
 	"github.com/tobiashort/monkey/lexer"
 	"github.com/tobiashort/monkey/parser"
 	"github.com/tobiashort/monkey/token"
@@ -24,6 +28,8 @@ func test(t *testing.T, input string, expectedAst ast.Ast) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	// spans are checked by TestParse20, leave them out elsewhere
+	actualAst = withoutSpans(reflect.ValueOf(actualAst)).Interface().(ast.Ast)
 
 	if !reflect.DeepEqual(expectedAst, actualAst) {
 		t.Fatalf(
@@ -36,6 +42,72 @@ func test(t *testing.T, input string, expectedAst ast.Ast) {
 			expectedAst,
 			actualAst)
 	}
+
+	p = parser.NewStream(lexer.NewReader("", iotest.OneByteReader(gostrings.NewReader(input))))
+	streamedAst, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamedAst = withoutSpans(reflect.ValueOf(streamedAst)).Interface().(ast.Ast)
+
+	if !reflect.DeepEqual(expectedAst, streamedAst) {
+		t.Fatalf(
+			strings.Dedent(`
+				           |Expected from stream:
+				           |%+v
+				           |
+				           |Got:
+				           |%+v`),
+			expectedAst,
+			streamedAst)
+	}
+}
+
+// withoutSpans returns a copy of v with the spans of all nodes and
+// tokens in it zeroed.
+func withoutSpans(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(withoutSpans(v.Elem()))
+		return c
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(withoutSpans(v.Elem()))
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := range v.Len() {
+			c.Index(i).Set(withoutSpans(v.Index(i)))
+		}
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		switch v.Type() {
+		case reflect.TypeFor[token.Span]():
+			c.SetZero()
+		case reflect.TypeFor[token.Token]():
+			c.FieldByName("Offset").SetInt(0)
+			c.FieldByName("End").SetInt(0)
+		default:
+			for i := range c.NumField() {
+				c.Field(i).Set(withoutSpans(c.Field(i)))
+			}
+		}
+		return c
+	default:
+		return v
+	}
 }
 
 func TestParse(t *testing.T) {
@@ -46,9 +118,7 @@ func TestParse(t *testing.T) {
 
 	expectedAst := ast.Ast{
 		ast.ExpressionStatement{
-			Type: ast.EXPR,
 			Expression: ast.IdentifierExpression{
-				Type: ast.IDENT,
 				Identifier: token.Token{
 					Type:    token.IDENT,
 					Literal: "foobar",
@@ -58,21 +128,18 @@ func TestParse(t *testing.T) {
 			},
 		},
 		ast.ExpressionStatement{
-			Type: ast.EXPR,
 			Expression: ast.LiteralExpression{
-				Type: ast.LITERAL,
 				Literal: token.Token{
 					Type:    token.STRING,
 					Literal: "\"foobar\"",
+					Value:   "foobar",
 					Line:    2,
 					Column:  1,
 				},
 			},
 		},
 		ast.ExpressionStatement{
-			Type: ast.EXPR,
 			Expression: ast.LiteralExpression{
-				Type: ast.LITERAL,
 				Literal: token.Token{
 					Type:    token.INT,
 					Literal: "42",
@@ -82,9 +149,7 @@ func TestParse(t *testing.T) {
 			},
 		},
 		ast.ExpressionStatement{
-			Type: ast.EXPR,
 			Expression: ast.LiteralExpression{
-				Type: ast.LITERAL,
 				Literal: token.Token{
 					Type:    token.FLOAT,
 					Literal: "42.0",
@@ -103,11 +168,8 @@ func TestParse2(t *testing.T) {
 
 	expectedAst := ast.Ast{
 		ast.ExpressionStatement{
-			Type: ast.EXPR,
 			Expression: ast.BinaryExpression{
-				Type: ast.BINARY,
 				Left: ast.LiteralExpression{
-					Type: ast.LITERAL,
 					Literal: token.Token{
 						Type:    token.INT,
 						Literal: "1",
@@ -122,7 +184,6 @@ func TestParse2(t *testing.T) {
 					Column:  3,
 				},
 				Right: ast.LiteralExpression{
-					Type: ast.LITERAL,
 					Literal: token.Token{
 						Type:    token.INT,
 						Literal: "2",
@@ -142,11 +203,8 @@ func TestParse3(t *testing.T) {
 
 	expectedAst := ast.Ast{
 		ast.ExpressionStatement{
-			Type: ast.EXPR,
 			Expression: ast.BinaryExpression{
-				Type: ast.BINARY,
 				Left: ast.LiteralExpression{
-					Type: ast.LITERAL,
 					Literal: token.Token{
 						Type:    token.INT,
 						Literal: "1",
@@ -161,9 +219,7 @@ func TestParse3(t *testing.T) {
 					Column:  3,
 				},
 				Right: ast.BinaryExpression{
-					Type: ast.BINARY,
 					Left: ast.LiteralExpression{
-						Type: ast.LITERAL,
 						Literal: token.Token{
 							Type:    token.INT,
 							Literal: "2",
@@ -178,7 +234,6 @@ func TestParse3(t *testing.T) {
 						Column:  7,
 					},
 					Right: ast.LiteralExpression{
-						Type: ast.LITERAL,
 						Literal: token.Token{
 							Type:    token.INT,
 							Literal: "3",
@@ -199,13 +254,9 @@ func TestParse4(t *testing.T) {
 
 	expectedAst := ast.Ast{
 		ast.ExpressionStatement{
-			Type: ast.EXPR,
 			Expression: ast.BinaryExpression{
-				Type: ast.BINARY,
 				Left: ast.BinaryExpression{
-					Type: ast.BINARY,
 					Left: ast.LiteralExpression{
-						Type: ast.LITERAL,
 						Literal: token.Token{
 							Type:    token.INT,
 							Literal: "1",
@@ -220,7 +271,6 @@ func TestParse4(t *testing.T) {
 						Column:  3,
 					},
 					Right: ast.LiteralExpression{
-						Type: ast.LITERAL,
 						Literal: token.Token{
 							Type:    token.INT,
 							Literal: "2",
@@ -236,7 +286,6 @@ func TestParse4(t *testing.T) {
 					Column:  7,
 				},
 				Right: ast.LiteralExpression{
-					Type: ast.LITERAL,
 					Literal: token.Token{
 						Type:    token.INT,
 						Literal: "3",
@@ -256,48 +305,46 @@ func TestParse5(t *testing.T) {
 
 	expectedAst := ast.Ast{
 		ast.ExpressionStatement{
-			Type: ast.EXPR,
-			Expression: ast.BinaryExpression{
-				Type: ast.BINARY,
-				Left: ast.LiteralExpression{
-					Type: ast.LITERAL,
-					Literal: token.Token{
-						Type:    token.INT,
-						Literal: "1",
-						Line:    1,
-						Column:  2,
-					},
-				},
-				Operator: token.Token{
-					Type:    token.ASTERISK,
-					Literal: "*",
-					Line:    1,
-					Column:  4,
-				},
-				Right: ast.BinaryExpression{
-					Type: ast.BINARY,
+			Expression: ast.ParenExpression{
+				Expression: ast.BinaryExpression{
 					Left: ast.LiteralExpression{
-						Type: ast.LITERAL,
 						Literal: token.Token{
 							Type:    token.INT,
-							Literal: "2",
+							Literal: "1",
 							Line:    1,
-							Column:  7,
+							Column:  2,
 						},
 					},
 					Operator: token.Token{
-						Type:    token.PLUS,
-						Literal: "+",
+						Type:    token.ASTERISK,
+						Literal: "*",
 						Line:    1,
-						Column:  9,
+						Column:  4,
 					},
-					Right: ast.LiteralExpression{
-						Type: ast.LITERAL,
-						Literal: token.Token{
-							Type:    token.INT,
-							Literal: "3",
-							Line:    1,
-							Column:  11,
+					Right: ast.ParenExpression{
+						Expression: ast.BinaryExpression{
+							Left: ast.LiteralExpression{
+								Literal: token.Token{
+									Type:    token.INT,
+									Literal: "2",
+									Line:    1,
+									Column:  7,
+								},
+							},
+							Operator: token.Token{
+								Type:    token.PLUS,
+								Literal: "+",
+								Line:    1,
+								Column:  9,
+							},
+							Right: ast.LiteralExpression{
+								Literal: token.Token{
+									Type:    token.INT,
+									Literal: "3",
+									Line:    1,
+									Column:  11,
+								},
+							},
 						},
 					},
 				},
@@ -313,7 +360,6 @@ func TestParse6(t *testing.T) {
 
 	expectedAst := ast.Ast{
 		ast.LetStatement{
-			Type: ast.LET,
 			Identifier: token.Token{
 				Type:    token.IDENT,
 				Literal: "a",
@@ -322,7 +368,6 @@ func TestParse6(t *testing.T) {
 				Column:  5,
 			},
 			Expression: ast.LiteralExpression{
-				Type: ast.LITERAL,
 				Literal: token.Token{
 					Type:    token.INT,
 					Literal: "42",
@@ -342,11 +387,8 @@ func TestParse7(t *testing.T) {
 
 	expectedAst := ast.Ast{
 		ast.ReturnStatement{
-			Type: ast.RETURN,
 			Expression: ast.BinaryExpression{
-				Type: ast.BINARY,
 				Left: ast.IdentifierExpression{
-					Type: ast.IDENT,
 					Identifier: token.Token{
 						Type:    token.IDENT,
 						Literal: "a",
@@ -363,7 +405,6 @@ func TestParse7(t *testing.T) {
 					Column:  10,
 				},
 				Right: ast.IdentifierExpression{
-					Type: ast.IDENT,
 					Identifier: token.Token{
 						Type:    token.IDENT,
 						Literal: "b",
@@ -385,11 +426,8 @@ func TestParse8(t *testing.T) {
 
 	expectedAst := ast.Ast{
 		ast.ExpressionStatement{
-			Type: ast.EXPR,
 			Expression: ast.BinaryExpression{
-				Type: ast.BINARY,
 				Left: ast.LiteralExpression{
-					Type: ast.LITERAL,
 					Literal: token.Token{
 						Type:    token.INT,
 						Literal: "1",
@@ -406,7 +444,6 @@ func TestParse8(t *testing.T) {
 					Column:  3,
 				},
 				Right: ast.UnaryExpression{
-					Type: ast.UNARY,
 					Operator: token.Token{
 						Type:    token.MINUS,
 						Literal: "-",
@@ -415,7 +452,6 @@ func TestParse8(t *testing.T) {
 						Column:  5,
 					},
 					Right: ast.LiteralExpression{
-						Type: ast.LITERAL,
 						Literal: token.Token{
 							Type:    token.INT,
 							Literal: "2",
@@ -428,11 +464,8 @@ func TestParse8(t *testing.T) {
 			},
 		},
 		ast.ExpressionStatement{
-			Type: ast.EXPR,
 			Expression: ast.BinaryExpression{
-				Type: ast.BINARY,
 				Left: ast.IdentifierExpression{
-					Type: ast.IDENT,
 					Identifier: token.Token{
 						Type:    token.IDENT,
 						Literal: "foo",
@@ -449,7 +482,6 @@ func TestParse8(t *testing.T) {
 					Column:  5,
 				},
 				Right: ast.UnaryExpression{
-					Type: ast.UNARY,
 					Operator: token.Token{
 						Type:    token.BANG,
 						Literal: "!",
@@ -458,7 +490,6 @@ func TestParse8(t *testing.T) {
 						Column:  8,
 					},
 					Right: ast.IdentifierExpression{
-						Type: ast.IDENT,
 						Identifier: token.Token{
 							Type:    token.IDENT,
 							Literal: "bar",
@@ -484,15 +515,11 @@ func TestParse9(t *testing.T) {
 
 	expectedAst := ast.Ast{
 		ast.Block{
-			Type: ast.BLOCK,
 			Ast: ast.Ast{
 				ast.Block{
-					Type: ast.BLOCK,
 					Ast: ast.Ast{
 						ast.ExpressionStatement{
-							Type: ast.EXPR,
 							Expression: ast.LiteralExpression{
-								Type: ast.LITERAL,
 								Literal: token.Token{
 									Type:    token.INT,
 									Literal: "42",
@@ -520,47 +547,43 @@ func TestParse10(t *testing.T) {
 
 	expectedAst := ast.Ast{
 		ast.IfStatement{
-			Type: ast.IF,
-			Condition: ast.BinaryExpression{
-				Type: ast.BINARY,
-				Left: ast.IdentifierExpression{
-					Type: ast.IDENT,
-					Identifier: token.Token{
-						Type:    token.IDENT,
-						Literal: "x",
-						File:    "",
-						Line:    1,
-						Column:  5,
+			Condition: ast.ParenExpression{
+				Expression: ast.BinaryExpression{
+					Left: ast.IdentifierExpression{
+						Identifier: token.Token{
+							Type:    token.IDENT,
+							Literal: "x",
+							File:    "",
+							Line:    1,
+							Column:  5,
+						},
 					},
-				},
-				Operator: token.Token{
-					Type:    token.EQUAL,
-					Literal: "==",
-					File:    "",
-					Line:    1,
-					Column:  7,
-				},
-				Right: ast.LiteralExpression{
-					Type: ast.LITERAL,
-					Literal: token.Token{
-						Type:    token.INT,
-						Literal: "23",
+					Operator: token.Token{
+						Type:    token.EQUAL,
+						Literal: "==",
 						File:    "",
 						Line:    1,
-						Column:  10,
+						Column:  7,
+					},
+					Right: ast.LiteralExpression{
+						Literal: token.Token{
+							Type:    token.INT,
+							Literal: "23",
+							File:    "",
+							Line:    1,
+							Column:  10,
+						},
 					},
 				},
 			},
 			Consequence: ast.Block{
-				Type: ast.BLOCK,
 				Ast: ast.Ast{
 					ast.ReturnStatement{
-						Type: ast.RETURN,
 						Expression: ast.LiteralExpression{
-							Type: ast.LITERAL,
 							Literal: token.Token{
 								Type:    token.STRING,
 								Literal: "\"great\"",
+								Value:   "great",
 								File:    "",
 								Line:    2,
 								Column:  10,
@@ -569,16 +592,14 @@ func TestParse10(t *testing.T) {
 					},
 				},
 			},
-			Alternative: ast.Block{
-				Type: ast.BLOCK,
+			Alternative: &ast.Block{
 				Ast: ast.Ast{
 					ast.ReturnStatement{
-						Type: ast.RETURN,
 						Expression: ast.LiteralExpression{
-							Type: ast.LITERAL,
 							Literal: token.Token{
 								Type:    token.STRING,
 								Literal: "\"also cool\"",
+								Value:   "also cool",
 								File:    "",
 								Line:    4,
 								Column:  10,
@@ -600,7 +621,6 @@ func TestParse11(t *testing.T) {
 
 	expectedAst := ast.Ast{
 		ast.Function{
-			Type: ast.FUNCTION,
 			Identifier: token.Token{
 				Type:    token.IDENT,
 				Literal: "f",
@@ -608,9 +628,8 @@ func TestParse11(t *testing.T) {
 				Line:    1,
 				Column:  4,
 			},
-			Parameters: []ast.Node{
+			Parameters: []ast.IdentifierExpression{
 				ast.IdentifierExpression{
-					Type: ast.IDENT,
 					Identifier: token.Token{
 						Type:    token.IDENT,
 						Literal: "a",
@@ -620,7 +639,6 @@ func TestParse11(t *testing.T) {
 					},
 				},
 				ast.IdentifierExpression{
-					Type: ast.IDENT,
 					Identifier: token.Token{
 						Type:    token.IDENT,
 						Literal: "b",
@@ -630,7 +648,6 @@ func TestParse11(t *testing.T) {
 					},
 				},
 				ast.IdentifierExpression{
-					Type: ast.IDENT,
 					Identifier: token.Token{
 						Type:    token.IDENT,
 						Literal: "c",
@@ -641,22 +658,27 @@ func TestParse11(t *testing.T) {
 				},
 			},
 			Block: ast.Block{
-				Type: ast.BLOCK,
 				Ast: ast.Ast{
 					ast.ReturnStatement{
-						Type: ast.RETURN,
 						Expression: ast.CallExpression{
-							Type: ast.CALL,
-							Identifier: token.Token{
-								Type:    token.IDENT,
-								Literal: "f",
+							Function: ast.IdentifierExpression{
+								Identifier: token.Token{
+									Type:    token.IDENT,
+									Literal: "f",
+									File:    "",
+									Line:    2,
+									Column:  9,
+								},
+							},
+							Paren: token.Token{
+								Type:    token.LPAREN,
+								Literal: "(",
 								File:    "",
 								Line:    2,
-								Column:  9,
+								Column:  10,
 							},
-							Parameters: []ast.Node{
+							Parameters: []ast.Expression{
 								ast.IdentifierExpression{
-									Type: ast.IDENT,
 									Identifier: token.Token{
 										Type:    token.IDENT,
 										Literal: "a",
@@ -666,7 +688,6 @@ func TestParse11(t *testing.T) {
 									},
 								},
 								ast.IdentifierExpression{
-									Type: ast.IDENT,
 									Identifier: token.Token{
 										Type:    token.IDENT,
 										Literal: "b",
@@ -676,7 +697,6 @@ func TestParse11(t *testing.T) {
 									},
 								},
 								ast.IdentifierExpression{
-									Type: ast.IDENT,
 									Identifier: token.Token{
 										Type:    token.IDENT,
 										Literal: "c",
@@ -701,7 +721,6 @@ func TestParse12(t *testing.T) {
 
 	expectedAst := ast.Ast{
 		ast.LetStatement{
-			Type: ast.LET,
 			Identifier: token.Token{
 				Type:    token.IDENT,
 				Literal: "a",
@@ -710,44 +729,39 @@ func TestParse12(t *testing.T) {
 				Column:  5,
 			},
 			Expression: ast.IfExpression{
-				Type: ast.IFEXPR,
-				Condition: ast.BinaryExpression{
-					Type: ast.BINARY,
-					Left: ast.IdentifierExpression{
-						Type: ast.IDENT,
-						Identifier: token.Token{
-							Type:    ast.IDENT,
-							Literal: "x",
-							File:    "",
-							Line:    1,
-							Column:  13,
+				Condition: ast.ParenExpression{
+					Expression: ast.BinaryExpression{
+						Left: ast.IdentifierExpression{
+							Identifier: token.Token{
+								Type:    token.IDENT,
+								Literal: "x",
+								File:    "",
+								Line:    1,
+								Column:  13,
+							},
 						},
-					},
-					Operator: token.Token{
-						Type:    token.GT,
-						Literal: ">",
-						File:    "",
-						Line:    1,
-						Column:  15,
-					},
-					Right: ast.LiteralExpression{
-						Type: ast.LITERAL,
-						Literal: token.Token{
-							Type:    token.INT,
-							Literal: "0",
+						Operator: token.Token{
+							Type:    token.GT,
+							Literal: ">",
 							File:    "",
 							Line:    1,
-							Column:  17,
+							Column:  15,
+						},
+						Right: ast.LiteralExpression{
+							Literal: token.Token{
+								Type:    token.INT,
+								Literal: "0",
+								File:    "",
+								Line:    1,
+								Column:  17,
+							},
 						},
 					},
 				},
 				Consequence: ast.Block{
-					Type: ast.BLOCK,
 					Ast: ast.Ast{
 						ast.YieldStatement{
-							Type: ast.YIELD,
 							Expression: ast.IdentifierExpression{
-								Type: ast.IDENT,
 								Identifier: token.Token{
 									Type:    token.IDENT,
 									Literal: "b",
@@ -760,12 +774,9 @@ func TestParse12(t *testing.T) {
 					},
 				},
 				Alternative: ast.Block{
-					Type: ast.BLOCK,
 					Ast: ast.Ast{
 						ast.YieldStatement{
-							Type: ast.YIELD,
 							Expression: ast.IdentifierExpression{
-								Type: ast.IDENT,
 								Identifier: token.Token{
 									Type:    token.IDENT,
 									Literal: "c",
@@ -789,7 +800,6 @@ func TestParse13(t *testing.T) {
 
 	expectedAst := ast.Ast{
 		ast.LetStatement{
-			Type: ast.LET,
 			Identifier: token.Token{
 				Type:    token.IDENT,
 				Literal: "add",
@@ -798,10 +808,8 @@ func TestParse13(t *testing.T) {
 				Column:  5,
 			},
 			Expression: ast.FunctionExpression{
-				Type: ast.FNEXPR,
-				Parameters: []ast.Node{
+				Parameters: []ast.IdentifierExpression{
 					ast.IdentifierExpression{
-						Type: ast.IDENT,
 						Identifier: token.Token{
 							Type:    token.IDENT,
 							Literal: "a",
@@ -811,7 +819,6 @@ func TestParse13(t *testing.T) {
 						},
 					},
 					ast.IdentifierExpression{
-						Type: ast.IDENT,
 						Identifier: token.Token{
 							Type:    token.IDENT,
 							Literal: "b",
@@ -822,14 +829,10 @@ func TestParse13(t *testing.T) {
 					},
 				},
 				Block: ast.Block{
-					Type: ast.BLOCK,
 					Ast: ast.Ast{
 						ast.ReturnStatement{
-							Type: ast.RETURN,
 							Expression: ast.BinaryExpression{
-								Type: ast.BINARY,
 								Left: ast.IdentifierExpression{
-									Type: ast.IDENT,
 									Identifier: token.Token{
 										Type:    token.IDENT,
 										Literal: "a",
@@ -846,7 +849,6 @@ func TestParse13(t *testing.T) {
 									Column:  30,
 								},
 								Right: ast.IdentifierExpression{
-									Type: ast.IDENT,
 									Identifier: token.Token{
 										Type:    token.IDENT,
 										Literal: "b",
@@ -864,3 +866,699 @@ func TestParse13(t *testing.T) {
 	}
 	test(t, input, expectedAst)
 }
+
+func TestParse14(t *testing.T) {
+	input := `let a = if (x) { yield 1; };`
+
+	l := lexer.New("", input)
+	tokens, err := l.Analyze()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = parser.New(tokens).Parse()
+	if err == nil {
+		t.Fatal("Expected error for if expression without else branch")
+	}
+}
+
+func TestParse15(t *testing.T) {
+	input := strings.Dedent(`let a = true;
+		                    |f(false, null);`)
+
+	expectedAst := ast.Ast{
+		ast.LetStatement{
+			Identifier: token.Token{
+				Type:    token.IDENT,
+				Literal: "a",
+				File:    "",
+				Line:    1,
+				Column:  5,
+			},
+			Expression: ast.LiteralExpression{
+				Literal: token.Token{
+					Type:    token.TRUE,
+					Literal: "true",
+					File:    "",
+					Line:    1,
+					Column:  9,
+				},
+			},
+		},
+		ast.ExpressionStatement{
+			Expression: ast.CallExpression{
+				Function: ast.IdentifierExpression{
+					Identifier: token.Token{
+						Type:    token.IDENT,
+						Literal: "f",
+						File:    "",
+						Line:    2,
+						Column:  1,
+					},
+				},
+				Paren: token.Token{
+					Type:    token.LPAREN,
+					Literal: "(",
+					File:    "",
+					Line:    2,
+					Column:  2,
+				},
+				Parameters: []ast.Expression{
+					ast.LiteralExpression{
+						Literal: token.Token{
+							Type:    token.FALSE,
+							Literal: "false",
+							File:    "",
+							Line:    2,
+							Column:  3,
+						},
+					},
+					ast.LiteralExpression{
+						Literal: token.Token{
+							Type:    token.NULL,
+							Literal: "null",
+							File:    "",
+							Line:    2,
+							Column:  10,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	test(t, input, expectedAst)
+}
+
+func TestParse16(t *testing.T) {
+	input := `[1, a][0][:b];`
+
+	expectedAst := ast.Ast{
+		ast.ExpressionStatement{
+			Expression: ast.SliceExpression{
+				Left: ast.IndexExpression{
+					Left: ast.ArrayLiteral{
+						Elements: []ast.Expression{
+							ast.LiteralExpression{
+								Literal: token.Token{
+									Type:    token.INT,
+									Literal: "1",
+									File:    "",
+									Line:    1,
+									Column:  2,
+								},
+							},
+							ast.IdentifierExpression{
+								Identifier: token.Token{
+									Type:    token.IDENT,
+									Literal: "a",
+									File:    "",
+									Line:    1,
+									Column:  5,
+								},
+							},
+						},
+					},
+					Bracket: token.Token{
+						Type:    token.LBRACKET,
+						Literal: "[",
+						File:    "",
+						Line:    1,
+						Column:  7,
+					},
+					Index: ast.LiteralExpression{
+						Literal: token.Token{
+							Type:    token.INT,
+							Literal: "0",
+							File:    "",
+							Line:    1,
+							Column:  8,
+						},
+					},
+				},
+				Bracket: token.Token{
+					Type:    token.LBRACKET,
+					Literal: "[",
+					File:    "",
+					Line:    1,
+					Column:  10,
+				},
+				Low: nil,
+				High: ast.IdentifierExpression{
+					Identifier: token.Token{
+						Type:    token.IDENT,
+						Literal: "b",
+						File:    "",
+						Line:    1,
+						Column:  12,
+					},
+				},
+			},
+		},
+	}
+
+	test(t, input, expectedAst)
+}
+
+func TestParse17(t *testing.T) {
+	input := `let h = {"a": 1};`
+
+	expectedAst := ast.Ast{
+		ast.LetStatement{
+			Identifier: token.Token{
+				Type:    token.IDENT,
+				Literal: "h",
+				File:    "",
+				Line:    1,
+				Column:  5,
+			},
+			Expression: ast.HashLiteral{
+				Brace: token.Token{
+					Type:    token.LBRACE,
+					Literal: "{",
+					File:    "",
+					Line:    1,
+					Column:  9,
+				},
+				Pairs: []ast.HashPair{
+					{
+						Key: ast.LiteralExpression{
+							Literal: token.Token{
+								Type:    token.STRING,
+								Literal: "\"a\"",
+								Value:   "a",
+								File:    "",
+								Line:    1,
+								Column:  10,
+							},
+						},
+						Value: ast.LiteralExpression{
+							Literal: token.Token{
+								Type:    token.INT,
+								Literal: "1",
+								File:    "",
+								Line:    1,
+								Column:  15,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	test(t, input, expectedAst)
+}
+
+func TestParse18(t *testing.T) {
+	input := `f(1)(2);`
+
+	expectedAst := ast.Ast{
+		ast.ExpressionStatement{
+			Expression: ast.CallExpression{
+				Function: ast.CallExpression{
+					Function: ast.IdentifierExpression{
+						Identifier: token.Token{
+							Type:    token.IDENT,
+							Literal: "f",
+							File:    "",
+							Line:    1,
+							Column:  1,
+						},
+					},
+					Paren: token.Token{
+						Type:    token.LPAREN,
+						Literal: "(",
+						File:    "",
+						Line:    1,
+						Column:  2,
+					},
+					Parameters: []ast.Expression{
+						ast.LiteralExpression{
+							Literal: token.Token{
+								Type:    token.INT,
+								Literal: "1",
+								File:    "",
+								Line:    1,
+								Column:  3,
+							},
+						},
+					},
+				},
+				Paren: token.Token{
+					Type:    token.LPAREN,
+					Literal: "(",
+					File:    "",
+					Line:    1,
+					Column:  5,
+				},
+				Parameters: []ast.Expression{
+					ast.LiteralExpression{
+						Literal: token.Token{
+							Type:    token.INT,
+							Literal: "2",
+							File:    "",
+							Line:    1,
+							Column:  6,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	test(t, input, expectedAst)
+}
+
+func TestParse19(t *testing.T) {
+	input := "let a = 1;\nlet b = \"foo;"
+
+	p := parser.NewStream(lexer.NewReader("test.mk", gostrings.NewReader(input)))
+	_, err := p.Parse()
+	if err == nil {
+		t.Fatal("Expected error for unterminated string")
+	}
+	expected := "test.mk:2:9: unterminated string"
+	if actual := err.Error(); actual != expected {
+		t.Fatalf("Expected: %s, Got: %s", expected, actual)
+	}
+}
+
+func TestParse20(t *testing.T) {
+	input := strings.Dedent(`let a = [1, 2][0] + -f(3);
+	                        |if (a) { a; } else { ({"k": a}); }
+	                        |fn g(x) { return x[1:]; }`)
+
+	tokens, err := lexer.New("test.mk", input).Analyze()
+	if err != nil {
+		t.Fatal(err)
+	}
+	actualAst, err := parser.New(tokens).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spanned := []ast.Node{
+		actualAst[0],
+		actualAst[0].(ast.LetStatement).Expression,
+		actualAst[0].(ast.LetStatement).Expression.(ast.BinaryExpression).Left,
+		actualAst[0].(ast.LetStatement).Expression.(ast.BinaryExpression).Right,
+		actualAst[1],
+		*actualAst[1].(ast.IfStatement).Alternative,
+		actualAst[2],
+		actualAst[2].(ast.Function).Block.Ast[0].(ast.ReturnStatement).Expression,
+	}
+	expectedSources := []string{
+		"let a = [1, 2][0] + -f(3);",
+		"[1, 2][0] + -f(3)",
+		"[1, 2][0]",
+		"-f(3)",
+		"if (a) { a; } else { ({\"k\": a}); }",
+		"{ ({\"k\": a}); }",
+		"fn g(x) { return x[1:]; }",
+		"x[1:]",
+	}
+
+	for i, node := range spanned {
+		span := node.Span()
+		if actual := input[span.Offset:span.End]; actual != expectedSources[i] {
+			t.Fatalf("Expected: %s, Got: %s", expectedSources[i], actual)
+		}
+	}
+	if span := spanned[6].Span(); span.File != "test.mk" || span.Line != 3 || span.Column != 1 {
+		t.Fatalf("Expected span to start at test.mk:3:1, got %s:%d:%d", span.File, span.Line, span.Column)
+	}
+}
+
+func TestParse21(t *testing.T) {
+	input := strings.Dedent(`let a = ;
+	                        |let b = 2;
+	                        |fn f(x) { return x }
+	                        |let c = a +;
+	                        |if (b) { let = 1; b; }
+	                        |let d = 1 }  let e = ;
+	                        |puts(b);`)
+
+	tokens, err := lexer.New("test.mk", input).Analyze()
+	if err != nil {
+		t.Fatal(err)
+	}
+	actualAst, err := parser.New(tokens).Parse()
+
+	var errs diag.List
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected a diag.List, got %v", err)
+	}
+	expectedErrors := []string{
+		`test.mk:1:9: expected expression, got ";"`,
+		`test.mk:3:20: expected ";", got "}"`,
+		`test.mk:4:12: expected expression, got ";"`,
+		`test.mk:5:14: expected identifier, got "="`,
+		`test.mk:6:11: expected ";", got "}"`,
+		`test.mk:6:22: expected expression, got ";"`,
+	}
+	if len(errs) != len(expectedErrors) {
+		t.Fatalf("Expected %d errors, got %d: %v", len(expectedErrors), len(errs), errs)
+	}
+	for i, err := range errs {
+		if actual := err.Error(); actual != expectedErrors[i] {
+			t.Fatalf("Expected: %s, Got: %s", expectedErrors[i], actual)
+		}
+	}
+
+	expectedSource := strings.Dedent(`let b = 2;
+	                                 |fn f(x) {}
+	                                 |if b { b; }
+	                                 |puts(b);`)
+	if actual := actualAst.String(); actual != expectedSource {
+		t.Fatalf("Expected: %s, Got: %s", expectedSource, actual)
+	}
+}
+
+func TestParse22(t *testing.T) {
+	input := strings.Dedent(`{}
+	                        |fn f() {}
+	                        |f();
+	                        |let g = fn() {};
+	                        |if (a) {} else {}
+	                        |let h = if a {} else {};
+	                        |let i = { {} };`)
+
+	expectedAst := ast.Ast{
+		ast.Block{Ast: ast.Ast{}},
+		ast.Function{
+			Identifier: token.Token{Type: token.IDENT, Literal: "f", Line: 2, Column: 4},
+			Parameters: []ast.IdentifierExpression{},
+			Block:      ast.Block{Ast: ast.Ast{}},
+		},
+		ast.ExpressionStatement{
+			Expression: ast.CallExpression{
+				Function: ast.IdentifierExpression{
+					Identifier: token.Token{Type: token.IDENT, Literal: "f", Line: 3, Column: 1},
+				},
+				Paren:      token.Token{Type: token.LPAREN, Literal: "(", Line: 3, Column: 2},
+				Parameters: []ast.Expression{},
+			},
+		},
+		ast.LetStatement{
+			Identifier: token.Token{Type: token.IDENT, Literal: "g", Line: 4, Column: 5},
+			Expression: ast.FunctionExpression{
+				Parameters: []ast.IdentifierExpression{},
+				Block:      ast.Block{Ast: ast.Ast{}},
+			},
+		},
+		ast.IfStatement{
+			Condition: ast.ParenExpression{
+				Expression: ast.IdentifierExpression{
+					Identifier: token.Token{Type: token.IDENT, Literal: "a", Line: 5, Column: 5},
+				},
+			},
+			Consequence: ast.Block{Ast: ast.Ast{}},
+			Alternative: &ast.Block{Ast: ast.Ast{}},
+		},
+		ast.LetStatement{
+			Identifier: token.Token{Type: token.IDENT, Literal: "h", Line: 6, Column: 5},
+			Expression: ast.IfExpression{
+				Condition: ast.IdentifierExpression{
+					Identifier: token.Token{Type: token.IDENT, Literal: "a", Line: 6, Column: 12},
+				},
+				Consequence: ast.Block{Ast: ast.Ast{}},
+				Alternative: ast.Block{Ast: ast.Ast{}},
+			},
+		},
+		ast.LetStatement{
+			Identifier: token.Token{Type: token.IDENT, Literal: "i", Line: 7, Column: 5},
+			Expression: ast.Block{
+				Ast: ast.Ast{ast.Block{Ast: ast.Ast{}}},
+			},
+		},
+	}
+
+	test(t, input, expectedAst)
+}
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range []string{
+		`let a = 1 + 2 * 3;`,
+		`fn add(x, y) { return x + y; }`,
+		`if (a < b) { a; } else { b; }`,
+		`let c = if (a) { yield [1, 2.5][0]; } else { yield {"k": null}["k"]; };`,
+		`let d = { let e = xs[1:]; yield e[:2]; };`,
+		`fn(x) { return x; }(1);`,
+		`{} fn f() {} f(); f(,); (); [; {:}; let = ; if {`,
+		`let a = -!b(c)[d:e]; /* comment */ "\t"`,
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		// must not panic, whatever errors there are, and also not on the
+		// tokens before a lexer error
+		tokens, _ := lexer.New("fuzz.mk", input).Analyze()
+		parser.New(tokens).Parse()
+		parser.NewStream(lexer.New("fuzz.mk", input)).Parse()
+	})
+}
+
+func TestParse23(t *testing.T) {
+	input := strings.Dedent(`f(fn(x) { if (x) { g((x)); } else { yield {"a": (1)}; } }, {(1): {}}, h(i(), (j)));
+	                        |let k = { {} { l; } };
+	                        |let m = {a + b: {}, "c": [1]};`)
+
+	tokens, err := lexer.New("test.mk", input).Analyze()
+	if err != nil {
+		t.Fatal(err)
+	}
+	actualAst, err := parser.New(tokens).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedSource := strings.Dedent(`f(fn(x) { if x { g(x); } else { yield {"a": 1}; } }, {1: {}}, h(i(), j));
+	                                 |let k = { {} { l; } };
+	                                 |let m = {(a + b): {}, "c": [1]};`)
+	if actual := actualAst.String(); actual != expectedSource {
+		t.Fatalf("Expected: %s, Got: %s", expectedSource, actual)
+	}
+}
+
+func TestParse24(t *testing.T) {
+	inputs := []string{
+		`-a + b;`,
+		`!x == y;`,
+		`-f(x)[0] * 2;`,
+		`!-a;`,
+		`a - b - c;`,
+		`a + b * c - d / e;`,
+		`a || b && c | d & e == f < g + h * -i;`,
+		`-(a + b);`,
+	}
+	expected := []string{
+		`((-a) + b);`,
+		`((!x) == y);`,
+		`((-f(x)[0]) * 2);`,
+		`(!(-a));`,
+		`((a - b) - c);`,
+		`((a + (b * c)) - (d / e));`,
+		`(a || (b && (c | (d & (e == (f < (g + (h * (-i)))))))));`,
+		`(-(a + b));`,
+	}
+	for i, input := range inputs {
+		tokens, err := lexer.New("test.mk", input).Analyze()
+		if err != nil {
+			t.Fatal(err)
+		}
+		actualAst, err := parser.New(tokens).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := actualAst.String(); actual != expected[i] {
+			t.Fatalf("Expected: %s, Got: %s", expected[i], actual)
+		}
+	}
+}
+
+func TestParse25(t *testing.T) {
+	input := strings.Dedent(`let x = { 1 +; 2; };
+	                        |{ 3 +; 4; }
+	                        |let y = { 5 * ; {6;} };
+	                        |let z = {7 +: 8};
+	                        |{ let h = {"a": 1, "b" 2}; h; }`)
+
+	tokens, err := lexer.New("test.mk", input).Analyze()
+	if err != nil {
+		t.Fatal(err)
+	}
+	actualAst, err := parser.New(tokens).Parse()
+
+	var errs diag.List
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected a diag.List, got %v", err)
+	}
+	expectedErrors := []string{
+		`test.mk:1:14: expected expression, got ";"`,
+		`test.mk:2:6: expected expression, got ";"`,
+		`test.mk:3:15: expected expression, got ";"`,
+		`test.mk:4:13: expected expression, got ":"`,
+		`test.mk:5:24: expected operator, got "2"`,
+	}
+	if len(errs) != len(expectedErrors) {
+		t.Fatalf("Expected %d errors, got %d: %v", len(expectedErrors), len(errs), errs)
+	}
+	for i, err := range errs {
+		if actual := err.Error(); actual != expectedErrors[i] {
+			t.Fatalf("Expected: %s, Got: %s", expectedErrors[i], actual)
+		}
+	}
+
+	expectedSource := strings.Dedent(`let x = { 2; };
+	                                 |{ 4; }
+	                                 |let y = { { 6; } };
+	                                 |{ h; }`)
+	if actual := actualAst.String(); actual != expectedSource {
+		t.Fatalf("Expected: %s, Got: %s", expectedSource, actual)
+	}
+}
+
+func TestParse26(t *testing.T) {
+	input := strings.Dedent(`let f = fn(1) { 1; };
+	                        |fn g(a, b + c) {}
+	                        |fn h(a,) {}`)
+
+	tokens, err := lexer.New("test.mk", input).Analyze()
+	if err != nil {
+		t.Fatal(err)
+	}
+	actualAst, err := parser.New(tokens).Parse()
+
+	var errs diag.List
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected a diag.List, got %v", err)
+	}
+	expectedErrors := []string{
+		`test.mk:1:12: expected identifier, got "1"`,
+		`test.mk:2:11: expected ")", got "+"`,
+	}
+	if len(errs) != len(expectedErrors) {
+		t.Fatalf("Expected %d errors, got %d: %v", len(expectedErrors), len(errs), errs)
+	}
+	for i, err := range errs {
+		if errs[i].Code != diag.UNEXPECTED_TOKEN {
+			t.Fatalf("Expected code %s, got %s", diag.UNEXPECTED_TOKEN, errs[i].Code)
+		}
+		if actual := err.Error(); actual != expectedErrors[i] {
+			t.Fatalf("Expected: %s, Got: %s", expectedErrors[i], actual)
+		}
+	}
+
+	if actual := actualAst.String(); actual != "fn h(a) {}" {
+		t.Fatalf("Expected: fn h(a) {}, Got: %s", actualAst.String())
+	}
+}
+
+func TestParse27(t *testing.T) {
+	input := `let a = (b + c) * (d);`
+
+	tokens, err := lexer.New("test.mk", input).Analyze()
+	if err != nil {
+		t.Fatal(err)
+	}
+	actualAst, err := parser.New(tokens).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	product := actualAst[0].(ast.LetStatement).Expression.(ast.BinaryExpression)
+	spanned := []ast.Node{
+		product,
+		product.Left,
+		product.Left.(ast.ParenExpression).Expression,
+		product.Right,
+	}
+	expectedSources := []string{
+		"(b + c) * (d)",
+		"(b + c)",
+		"b + c",
+		"(d)",
+	}
+
+	for i, node := range spanned {
+		span := node.Span()
+		if actual := input[span.Offset:span.End]; actual != expectedSources[i] {
+			t.Fatalf("Expected: %s, Got: %s", expectedSources[i], actual)
+		}
+	}
+}
+
+func TestParse28(t *testing.T) {
+	unregister := parser.RegisterRightInfix(token.ASSIGN)
+	defer unregister()
+
+	tokens, err := lexer.New("test.mk", `a = b = c - d = e;`).Analyze()
+	if err != nil {
+		t.Fatal(err)
+	}
+	actualAst, err := parser.New(tokens).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "(a = (b = ((c - d) = e)));"
+	if actual := actualAst.String(); actual != expected {
+		t.Fatalf("Expected: %s, Got: %s", expected, actual)
+	}
+}
+
+func TestParse29(t *testing.T) {
+	inputs := []string{`fn f(a, b`, `f(1, 2`, `[1, 2`, `{ 1;`}
+	expectedCodes := []diag.Code{diag.UNCLOSED_PARAMETERS, diag.UNCLOSED_ARGUMENTS, diag.UNEXPECTED_TOKEN, diag.UNCLOSED_BLOCK}
+	expectedErrors := []string{
+		`test.mk:1:5: unclosed parameter list`,
+		`test.mk:1:2: unclosed argument list`,
+		`test.mk:1:6: expected "]", got end of input`,
+		`test.mk:1:1: unclosed block`,
+	}
+
+	for i, input := range inputs {
+		tokens, err := lexer.New("test.mk", input).Analyze()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = parser.New(tokens).Parse()
+
+		var errs diag.List
+		if !errors.As(err, &errs) || len(errs) != 1 {
+			t.Fatalf("Expected a single error for %s, got %v", input, err)
+		}
+		if errs[0].Code != expectedCodes[i] {
+			t.Fatalf("Expected code %s for %s, got %s", expectedCodes[i], input, errs[0].Code)
+		}
+		if actual := errs[0].Error(); actual != expectedErrors[i] {
+			t.Fatalf("Expected: %s, Got: %s", expectedErrors[i], actual)
+		}
+	}
+}
+
+func BenchmarkParseNested(b *testing.B) {
+	const depth = 500
+	inputs := []struct {
+		name  string
+		input string
+	}{
+		{"blocks", gostrings.Repeat("{ let a = 1; ", depth) + gostrings.Repeat("}", depth)},
+		{"calls", "f" + gostrings.Repeat("(f", depth) + gostrings.Repeat(")", depth) + ";"},
+		{"functions", "let f = " + gostrings.Repeat("fn(x) { return ", depth) + "x" + gostrings.Repeat("; }", depth) + ";"},
+	}
+	for _, in := range inputs {
+		tokens, err := lexer.New("", in.input).Analyze()
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(in.name, func(b *testing.B) {
+			for b.Loop() {
+				if _, err := parser.New(tokens).Parse(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package parser
+
+import (
+	"github.com/tobiashort/monkey/ast"
+	"github.com/tobiashort/monkey/token"
+)
+
+// Binding powers of the operators, from the loosest to the tightest.
+const (
+	lowest = iota
+	logicalOr
+	logicalAnd
+	bitwiseOr
+	bitwiseAnd
+	equality
+	comparison
+	sum
+	product
+	unary
+	postfix
+)
+
+type associativity int
+
+const (
+	leftAssociative associativity = iota
+	rightAssociative
+)
+
+// prefixParser parses the expression starting with the token at the
+// current position.
+type prefixParser func(p *Parser) (ast.Expression, error)
+
+// operator is an infix or postfix operator. parse parses it from its
+// token at the current position, with left the expression before it.
+type operator struct {
+	power int
+	assoc associativity
+	parse func(p *Parser, left ast.Expression, op operator) (ast.Expression, error)
+}
+
+var (
+	prefixes  = map[token.TokenType]prefixParser{}
+	operators = map[token.TokenType]operator{}
+	// closers may follow an expression and end it
+	closers = map[token.TokenType]bool{
+		token.EOF:       true,
+		token.SEMICOLON: true,
+		token.COMMA:     true,
+		token.COLON:     true,
+		token.RPAREN:    true,
+		token.LBRACE:    true,
+		token.RBRACE:    true,
+		token.RBRACKET:  true,
+	}
+)
+
+// the tables refer to methods that use them, so they are filled in init
+func init() {
+	registerPrefix(token.IDENT, (*Parser).parseIdentifier)
+	registerPrefix(token.INT, (*Parser).parseLiteral)
+	registerPrefix(token.FLOAT, (*Parser).parseLiteral)
+	registerPrefix(token.STRING, (*Parser).parseLiteral)
+	registerPrefix(token.TRUE, (*Parser).parseLiteral)
+	registerPrefix(token.FALSE, (*Parser).parseLiteral)
+	registerPrefix(token.NULL, (*Parser).parseLiteral)
+	registerPrefix(token.LPAREN, (*Parser).parseGrouped)
+	registerPrefix(token.LBRACKET, (*Parser).parseArrayLiteral)
+	registerPrefix(token.LBRACE, (*Parser).parseBraces)
+	registerPrefix(token.IF, (*Parser).parseIfExpr)
+	registerPrefix(token.FUNCTION, (*Parser).parseFunctionExpr)
+	registerPrefix(token.MINUS, (*Parser).parseUnary)
+	registerPrefix(token.BANG, (*Parser).parseUnary)
+
+	registerInfix(token.LOR, logicalOr, leftAssociative)
+	registerInfix(token.LAND, logicalAnd, leftAssociative)
+	registerInfix(token.BOR, bitwiseOr, leftAssociative)
+	registerInfix(token.BAND, bitwiseAnd, leftAssociative)
+	registerInfix(token.EQUAL, equality, leftAssociative)
+	registerInfix(token.NOT_EQUAL, equality, leftAssociative)
+	registerInfix(token.LT, comparison, leftAssociative)
+	registerInfix(token.GT, comparison, leftAssociative)
+	registerInfix(token.LEQT, comparison, leftAssociative)
+	registerInfix(token.GEQT, comparison, leftAssociative)
+	registerInfix(token.PLUS, sum, leftAssociative)
+	registerInfix(token.MINUS, sum, leftAssociative)
+	registerInfix(token.ASTERISK, product, leftAssociative)
+	registerInfix(token.SLASH, product, leftAssociative)
+
+	registerPostfix(token.LPAREN, (*Parser).parseCallExpression)
+	registerPostfix(token.LBRACKET, (*Parser).parseIndexExpression)
+}
+
+// registerPrefix registers parse for the expressions starting with t.
+func registerPrefix(t token.TokenType, parse prefixParser) {
+	prefixes[t] = parse
+}
+
+// registerInfix registers t as a binary operator. This is the only
+// entry a new operator needs: the operators are looked up before closers,
+// which list the tokens that end an expression without being one.
+func registerInfix(t token.TokenType, power int, assoc associativity) {
+	operators[t] = operator{power: power, assoc: assoc, parse: (*Parser).parseBinary}
+}
+
+// registerPostfix registers parse for the postfix operator t, like a
+// call, which binds tighter than any infix or prefix operator.
+func registerPostfix(t token.TokenType, parse func(p *Parser, left ast.Expression) (ast.Expression, error)) {
+	operators[t] = operator{
+		power: postfix,
+		parse: func(p *Parser, left ast.Expression, _ operator) (ast.Expression, error) {
+			return parse(p, left)
+		},
+	}
+}
+
+// parseExpression parses an expression of operators binding tighter
+// than bindingPower, and stops at its last token.
+func (p *Parser) parseExpression(bindingPower int) (ast.Expression, error) {
+	parsePrefix, ok := prefixes[p.token().Type]
+	if !ok {
+		return nil, unexpected(p.token(), "expression")
+	}
+	left, err := parsePrefix(p)
+	if err != nil {
+		return nil, err
+	}
+
+	for p.hasNext() {
+		op, ok := operators[p.peekToken().Type]
+		if !ok {
+			if closers[p.peekToken().Type] {
+				break
+			}
+			return nil, unexpected(p.peekToken(), "operator")
+		}
+		if op.power <= bindingPower {
+			break
+		}
+		p.nextToken()
+		if left, err = op.parse(p, left, op); err != nil {
+			return nil, err
+		}
+	}
+
+	return left, nil
+}
+
+func (p *Parser) parseIdentifier() (ast.Expression, error) {
+	return ast.IdentifierExpression{Identifier: p.token()}, nil
+}
+
+func (p *Parser) parseLiteral() (ast.Expression, error) {
+	return ast.LiteralExpression{Literal: p.token()}, nil
+}
+
+func (p *Parser) parseGrouped() (ast.Expression, error) {
+	lparen := p.token()
+	if err := p.expect(token.LPAREN); err != nil {
+		return nil, err
+	}
+	p.nextToken()
+	expr, err := p.parseExpression(lowest)
+	if err != nil {
+		return nil, err
+	}
+	p.nextToken()
+	if err := p.expect(token.RPAREN); err != nil {
+		return nil, err
+	}
+	return ast.ParenExpression{
+		Expression: expr,
+		Range:      lparen.Span().To(p.token().Span()),
+	}, nil
+}
+
+func (p *Parser) parseUnary() (ast.Expression, error) {
+	operator := p.token()
+	p.nextToken()
+	right, err := p.parseExpression(unary)
+	if err != nil {
+		return nil, err
+	}
+	return ast.UnaryExpression{
+		Operator: operator,
+		Right:    right,
+		Range:    operator.Span().To(right.Span()),
+	}, nil
+}
+
+func (p *Parser) parseBinary(left ast.Expression, op operator) (ast.Expression, error) {
+	operator := p.token()
+	p.nextToken()
+	power := op.power
+	if op.assoc == rightAssociative {
+		// let an operator of the same power bind the right operand first
+		power--
+	}
+	right, err := p.parseExpression(power)
+	if err != nil {
+		return nil, err
+	}
+	return ast.BinaryExpression{
+		Left:     left,
+		Operator: operator,
+		Right:    right,
+		Range:    left.Span().To(right.Span()),
+	}, nil
+}
+
+func (p *Parser) parseCallExpression(left ast.Expression) (ast.Expression, error) {
+	call := ast.CallExpression{
+		Function: left,
+		Paren:    p.token(),
+	}
+	if params, err := p.parseArguments(); err != nil {
+		return nil, err
+	} else {
+		call.Parameters = params
+	}
+	call.Range = left.Span().To(p.token().Span())
+	return call, nil
+}
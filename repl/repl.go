@@ -2,20 +2,23 @@ package repl
 
 import (
 	"bufio"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"os"
 
+	"github.com/tobiashort/monkey/diag"
+	"github.com/tobiashort/monkey/evaluator"
 	"github.com/tobiashort/monkey/lexer"
+	"github.com/tobiashort/monkey/object"
 	"github.com/tobiashort/monkey/parser"
-	"github.com/tobiashort/utils-go/must"
 )
 
 const PROMPT = ">> "
 
 func Start(w io.Writer, r io.Reader) {
 	scanner := bufio.NewScanner(r)
+	env := object.NewEnvironment()
+	env.SetOutput(w)
 
 	for {
 		fmt.Fprintf(w, PROMPT)
@@ -29,17 +32,34 @@ func Start(w io.Writer, r io.Reader) {
 		l := lexer.New("stdin", line)
 		tokens, err := l.Analyze()
 		if err != nil {
-			fmt.Fprintf(w, "%v\n", err)
-			os.Exit(1)
+			report(w, err, line)
+			continue
 		}
 
 		p := parser.New(tokens)
 		ast, err := p.Parse()
 		if err != nil {
-			fmt.Fprintf(w, "%v\n", err)
-		} else {
-			j := string(must.Do2(json.MarshalIndent(ast, "", "  ")))
-			fmt.Fprintf(w, "%s\n", j)
+			report(w, err, line)
+			continue
 		}
+
+		if result := evaluator.Eval(ast, env); result != nil {
+			fmt.Fprintf(w, "%s\n", result.Inspect())
+		}
+	}
+}
+
+// report shows the diagnostics in err within the line they were found
+// in.
+func report(w io.Writer, err error, line string) {
+	var d diag.Diagnostic
+	var diags diag.List
+	switch {
+	case errors.As(err, &diags):
+		diag.RenderAll(w, diags, diag.Text(line))
+	case errors.As(err, &d):
+		diag.Render(w, d, diag.Text(line))
+	default:
+		fmt.Fprintf(w, "%v\n", err)
 	}
 }
@@ -1,20 +1,71 @@
 package token
 
-import "github.com/tobiashort/utils-go/errors"
+import "fmt"
 
 type TokenType = string
 
 type Token struct {
 	Type    TokenType
 	Literal string
-	File    string
-	Line    int
-	Column  int
+	// Value is the decoded value of a STRING literal, with the quotes
+	// removed and escape sequences resolved.
+	Value  string
+	File   string
+	Line   int
+	Column int
+	// Offset and End are the byte offsets of the first byte of the token
+	// and of the byte just past it.
+	Offset int
+	End    int
+}
+
+// Span returns the range of source the token was read from.
+func (t Token) Span() Span {
+	return Span{
+		File:   t.File,
+		Line:   t.Line,
+		Column: t.Column,
+		Offset: t.Offset,
+		End:    t.End,
+	}
+}
+
+// Span is a range of source, from byte Offset up to but excluding byte
+// End. Line and Column are the position of its start.
+type Span struct {
+	File   string
+	Line   int
+	Column int
+	Offset int
+	End    int
+}
+
+// To returns the span from the start of s to the end of e.
+func (s Span) To(e Span) Span {
+	s.End = e.End
+	return s
+}
+
+// Pos returns the position the span starts at.
+func (s Span) Pos() Position {
+	return Position{File: s.File, Line: s.Line, Column: s.Column}
+}
+
+// Position is a line and column of a source file, both counted from 1.
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
 }
 
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+	COMMENT = "COMMENT"
 
 	// Identifiers + literals
 	IDENT  = "IDENT"
@@ -43,45 +94,22 @@ const (
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
+	COLON     = ":"
 	LPAREN    = "("
 	RPAREN    = ")"
 	LBRACE    = "{"
 	RBRACE    = "}"
+	LBRACKET  = "["
+	RBRACKET  = "]"
 
 	// Keywords
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
+	NULL     = "NULL"
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
 	YIELD    = "YIELD"
 )
-
-func BindingPower(t Token) (int, error) {
-	switch t.Type {
-	case SEMICOLON, RPAREN, LBRACE:
-		return 0, nil
-	case LOR:
-		return 1, nil
-	case LAND:
-		return 2, nil
-	case BOR:
-		return 3, nil
-	case BAND:
-		return 4, nil
-	case EQUAL, NOT_EQUAL:
-		return 5, nil
-	case LT, GT, LEQT, GEQT:
-		return 6, nil
-	case PLUS, MINUS:
-		return 7, nil
-	case ASTERISK, SLASH:
-		return 8, nil
-	case BANG:
-		return 9, nil
-	default:
-		return -1, errors.WithCtxf("%s:%d:%d: illegal token type %q", t.File, t.Line, t.Column, t.Type)
-	}
-}